@@ -1,9 +1,10 @@
 package core
 
 type NamedColorChar struct {
-	Char rune
-	Fg   string
-	Bg   string
+	Char     rune
+	Fg       string
+	Bg       string
+	Grapheme string // full grapheme cluster if it has more than one rune, Char is its first rune
 }
 
 func (c NamedColorChar) HasBackground() bool {
@@ -12,12 +13,45 @@ func (c NamedColorChar) HasBackground() bool {
 
 func (c NamedColorChar) WithBackground(bg string) NamedColorChar {
 	return NamedColorChar{
-		Char: c.Char,
-		Fg:   c.Fg,
-		Bg:   bg,
+		Char:     c.Char,
+		Fg:       c.Fg,
+		Bg:       bg,
+		Grapheme: c.Grapheme,
 	}
 }
 
+// SplitGrapheme returns the first rune of a grapheme cluster, and the cluster
+// itself if it has more than one rune. Single rune clusters need no string.
+func SplitGrapheme(cluster string) (rune, string) {
+	runes := []rune(cluster)
+	switch len(runes) {
+	case 0:
+		return 0, ""
+	case 1:
+		return runes[0], ""
+	}
+	return runes[0], cluster
+}
+
+// GraphemeText returns the grapheme cluster stored by SplitGrapheme.
+func GraphemeText(char rune, grapheme string) string {
+	if grapheme != "" {
+		return grapheme
+	}
+	return string(char)
+}
+
+// WithGrapheme returns the char showing the given grapheme cluster.
+func (c NamedColorChar) WithGrapheme(cluster string) NamedColorChar {
+	c.Char, c.Grapheme = SplitGrapheme(cluster)
+	return c
+}
+
+// Text returns the grapheme cluster shown by the char.
+func (c NamedColorChar) Text() string {
+	return GraphemeText(c.Char, c.Grapheme)
+}
+
 func (c NamedColorChar) IsEmpty() bool {
-	return c.Char == 0 && c.Fg == "" && c.Bg == ""
+	return c.Char == 0 && c.Fg == "" && c.Bg == "" && c.Grapheme == ""
 }
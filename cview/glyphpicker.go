@@ -0,0 +1,325 @@
+package cview
+
+import (
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/memmaker/go/fxtools"
+)
+
+// GlyphBlock is a named set of glyphs shown on one page of a GlyphPicker.
+type GlyphBlock struct {
+	Name   string
+	Glyphs []rune
+}
+
+// NewGlyphBlockRange returns a block with all runes from first to last,
+// inclusive.
+func NewGlyphBlockRange(name string, first, last rune) GlyphBlock {
+	glyphs := make([]rune, 0, last-first+1)
+	for r := first; r <= last; r++ {
+		glyphs = append(glyphs, r)
+	}
+	return GlyphBlock{Name: name, Glyphs: glyphs}
+}
+
+// NewCP437GlyphBlock returns a block with the 256 glyphs of code page 437.
+func NewCP437GlyphBlock() GlyphBlock {
+	glyphs := make([]rune, 256)
+	for i := range glyphs {
+		glyphs[i] = fxtools.CP437ToRune(byte(i))
+	}
+	glyphs[0] = ' '
+	return GlyphBlock{Name: "CP437", Glyphs: glyphs}
+}
+
+// DefaultGlyphBlocks returns the blocks shown by a new GlyphPicker: CP437
+// followed by the Unicode blocks most useful for tiles.
+func DefaultGlyphBlocks() []GlyphBlock {
+	return []GlyphBlock{
+		NewCP437GlyphBlock(),
+		NewGlyphBlockRange("Latin-1 Supplement", 0x00A1, 0x00FF),
+		NewGlyphBlockRange("Greek", 0x0391, 0x03C9),
+		NewGlyphBlockRange("Arrows", 0x2190, 0x21FF),
+		NewGlyphBlockRange("Mathematical Operators", 0x2200, 0x22FF),
+		NewGlyphBlockRange("Box Drawing", 0x2500, 0x257F),
+		NewGlyphBlockRange("Block Elements", 0x2580, 0x259F),
+		NewGlyphBlockRange("Geometric Shapes", 0x25A0, 0x25FF),
+		NewGlyphBlockRange("Miscellaneous Symbols", 0x2600, 0x26FF),
+		NewGlyphBlockRange("Dingbats", 0x2700, 0x27BF),
+		NewGlyphBlockRange("Braille Patterns", 0x2800, 0x28FF),
+	}
+}
+
+// GlyphPicker shows a grid of glyphs, one block at a time, and lets the user
+// pick one.
+type GlyphPicker struct {
+	*Box
+
+	// The blocks the user can switch between.
+	blocks []GlyphBlock
+
+	// The index of the shown block.
+	block int
+
+	// The index of the glyph under the cursor in the shown block.
+	cursor int
+
+	// The first shown row of glyphs.
+	rowOffset int
+
+	// The number of glyphs per row, as of the last draw.
+	columns int
+
+	// Colors of the glyphs.
+	glyphStyle tcell.Style
+
+	// Color of the block name.
+	labelColor tcell.Color
+
+	// An optional function which is called when the user picked a glyph.
+	selected func(glyph rune)
+
+	// An optional function which is called when the user leaves the picker
+	// with Escape, Tab or Backtab.
+	done func(key tcell.Key)
+
+	sync.RWMutex
+}
+
+// NewGlyphPicker returns a new glyph picker showing DefaultGlyphBlocks.
+func NewGlyphPicker() *GlyphPicker {
+	g := &GlyphPicker{
+		Box:        NewBox(),
+		blocks:     DefaultGlyphBlocks(),
+		columns:    1,
+		glyphStyle: tcell.StyleDefault.Foreground(Styles.PrimaryTextColor).Background(Styles.PrimitiveBackgroundColor),
+		labelColor: Styles.SecondaryTextColor,
+	}
+	return g
+}
+
+// SetBlocks sets the blocks the user can switch between.
+func (g *GlyphPicker) SetBlocks(blocks []GlyphBlock) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.blocks = blocks
+	g.block, g.cursor, g.rowOffset = 0, 0, 0
+}
+
+// SetGlyphColors sets the colors the glyphs are drawn with, eg. the colors
+// of the tile they are picked for.
+func (g *GlyphPicker) SetGlyphColors(fg, bg tcell.Color) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.glyphStyle = tcell.StyleDefault.Foreground(fg).Background(bg)
+}
+
+// SetLabelColor sets the color of the block name.
+func (g *GlyphPicker) SetLabelColor(color tcell.Color) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.labelColor = color
+}
+
+// SetSelectedFunc sets a handler which is called when the user picks a glyph
+// with Enter or a mouse click.
+func (g *GlyphPicker) SetSelectedFunc(handler func(glyph rune)) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.selected = handler
+}
+
+// SetDoneFunc sets a handler which is called when the user leaves the picker.
+// The handler receives the key that was pressed: KeyEscape, KeyTab or
+// KeyBacktab.
+func (g *GlyphPicker) SetDoneFunc(handler func(key tcell.Key)) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.done = handler
+}
+
+// SetCurrentGlyph moves the cursor to the given glyph, switching blocks if
+// necessary. It returns false if no block contains the glyph.
+func (g *GlyphPicker) SetCurrentGlyph(glyph rune) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	for blockIndex, block := range g.blocks {
+		for glyphIndex, r := range block.Glyphs {
+			if r == glyph {
+				g.block, g.cursor = blockIndex, glyphIndex
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetCurrentGlyph returns the glyph under the cursor.
+func (g *GlyphPicker) GetCurrentGlyph() rune {
+	g.RLock()
+	defer g.RUnlock()
+
+	return g.currentGlyph()
+}
+
+func (g *GlyphPicker) currentGlyph() rune {
+	if g.block >= len(g.blocks) || g.cursor >= len(g.blocks[g.block].Glyphs) {
+		return 0
+	}
+	return g.blocks[g.block].Glyphs[g.cursor]
+}
+
+func (g *GlyphPicker) switchBlock(delta int) {
+	if len(g.blocks) == 0 {
+		return
+	}
+	g.block = (g.block + delta + len(g.blocks)) % len(g.blocks)
+	g.cursor, g.rowOffset = 0, 0
+}
+
+func (g *GlyphPicker) moveCursor(delta int) {
+	if g.block >= len(g.blocks) {
+		return
+	}
+	cursor := g.cursor + delta
+	if cursor >= 0 && cursor < len(g.blocks[g.block].Glyphs) {
+		g.cursor = cursor
+	}
+}
+
+// Draw draws this primitive onto the screen.
+func (g *GlyphPicker) Draw(screen tcell.Screen) {
+	if !g.GetVisible() {
+		return
+	}
+
+	g.Box.Draw(screen)
+
+	g.Lock()
+	defer g.Unlock()
+
+	x, y, width, height := g.GetInnerRect()
+	if width < 2 || height < 2 || len(g.blocks) == 0 {
+		return
+	}
+	block := g.blocks[g.block]
+
+	// Draw the block name.
+	Print(screen, []byte("◀ "+Escape(block.Name)+" ▶"), x, y, width, AlignCenter, g.labelColor)
+
+	// Draw the glyphs, keeping the cursor visible.
+	g.columns = width / 2
+	rows := height - 1
+	cursorRow := g.cursor / g.columns
+	if cursorRow < g.rowOffset {
+		g.rowOffset = cursorRow
+	} else if cursorRow >= g.rowOffset+rows {
+		g.rowOffset = cursorRow - rows + 1
+	}
+	hasFocus := g.GetFocusable().HasFocus()
+	for row := 0; row < rows; row++ {
+		for column := 0; column < g.columns; column++ {
+			index := (g.rowOffset+row)*g.columns + column
+			if index >= len(block.Glyphs) {
+				return
+			}
+			style := g.glyphStyle
+			if index == g.cursor && hasFocus {
+				style = style.Reverse(true)
+			}
+			screen.SetContent(x+column*2, y+1+row, block.Glyphs[index], nil, style)
+		}
+	}
+}
+
+// InputHandler returns the handler for this primitive.
+func (g *GlyphPicker) InputHandler() func(event *tcell.EventKey, setFocus func(p Primitive)) {
+	return g.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p Primitive)) {
+		g.Lock()
+		switch {
+		case HitShortcut(event, Keys.MoveLeft, Keys.MoveLeft2):
+			g.moveCursor(-1)
+		case HitShortcut(event, Keys.MoveRight, Keys.MoveRight2):
+			g.moveCursor(1)
+		case HitShortcut(event, Keys.MoveUp, Keys.MoveUp2):
+			g.moveCursor(-g.columns)
+		case HitShortcut(event, Keys.MoveDown, Keys.MoveDown2):
+			g.moveCursor(g.columns)
+		case HitShortcut(event, Keys.MovePreviousPage):
+			g.switchBlock(-1)
+		case HitShortcut(event, Keys.MoveNextPage):
+			g.switchBlock(1)
+		case HitShortcut(event, Keys.Select, Keys.Select2):
+			glyph, selected := g.currentGlyph(), g.selected
+			g.Unlock()
+			if selected != nil && glyph != 0 {
+				selected(glyph)
+			}
+			return
+		case HitShortcut(event, Keys.Cancel, Keys.MovePreviousField, Keys.MoveNextField):
+			done := g.done
+			g.Unlock()
+			if done != nil {
+				done(event.Key())
+			}
+			return
+		}
+		g.Unlock()
+	})
+}
+
+// MouseHandler returns the mouse handler for this primitive.
+func (g *GlyphPicker) MouseHandler() func(action MouseAction, event *tcell.EventMouse, setFocus func(p Primitive)) (consumed bool, capture Primitive) {
+	return g.WrapMouseHandler(func(action MouseAction, event *tcell.EventMouse, setFocus func(p Primitive)) (consumed bool, capture Primitive) {
+		x, y := event.Position()
+		if !g.InRect(x, y) {
+			return false, nil
+		}
+		rectX, rectY, width, _ := g.GetInnerRect()
+
+		switch action {
+		case MouseLeftClick:
+			setFocus(g)
+			g.Lock()
+			if y == rectY {
+				if x < rectX+width/2 {
+					g.switchBlock(-1)
+				} else {
+					g.switchBlock(1)
+				}
+				g.Unlock()
+				return true, nil
+			}
+			index := (g.rowOffset+y-rectY-1)*g.columns + (x-rectX)/2
+			if g.block >= len(g.blocks) || index < 0 || index >= len(g.blocks[g.block].Glyphs) {
+				g.Unlock()
+				return true, nil
+			}
+			g.cursor = index
+			glyph, selected := g.currentGlyph(), g.selected
+			g.Unlock()
+			if selected != nil {
+				selected(glyph)
+			}
+			consumed = true
+		case MouseScrollUp:
+			g.Lock()
+			g.moveCursor(-g.columns)
+			g.Unlock()
+			consumed = true
+		case MouseScrollDown:
+			g.Lock()
+			g.moveCursor(g.columns)
+			g.Unlock()
+			consumed = true
+		}
+		return
+	})
+}
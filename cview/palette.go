@@ -0,0 +1,49 @@
+package cview
+
+import (
+	"image/color"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// NamedColors is a source of colors looked up by name, such as
+// textiles.ColorPalette.
+type NamedColors interface {
+	Has(name string) bool
+	Get(name string) color.RGBA
+}
+
+// WithPalette returns a copy of the theme with every color that has a
+// matching name in the palette replaced. The names are the field names in
+// snake case without the "_color" suffix, for example "title", "border",
+// "primary_text" or "primitive_background".
+func (t Theme) WithPalette(palette NamedColors) Theme {
+	set := func(name string, target *tcell.Color) {
+		if !palette.Has(name) {
+			return
+		}
+		c := palette.Get(name)
+		*target = tcell.NewRGBColor(int32(c.R), int32(c.G), int32(c.B))
+	}
+	set("title", &t.TitleColor)
+	set("border", &t.BorderColor)
+	set("graphics", &t.GraphicsColor)
+	set("primary_text", &t.PrimaryTextColor)
+	set("secondary_text", &t.SecondaryTextColor)
+	set("tertiary_text", &t.TertiaryTextColor)
+	set("inverse_text", &t.InverseTextColor)
+	set("contrast_primary_text", &t.ContrastPrimaryTextColor)
+	set("contrast_secondary_text", &t.ContrastSecondaryTextColor)
+	set("primitive_background", &t.PrimitiveBackgroundColor)
+	set("contrast_background", &t.ContrastBackgroundColor)
+	set("more_contrast_background", &t.MoreContrastBackgroundColor)
+	set("scroll_bar", &t.ScrollBarColor)
+	return t
+}
+
+// SetStylesFromPalette applies a palette to Styles, see Theme.WithPalette.
+// Primitives read Styles when they are created, so only primitives created
+// afterwards use the new colors.
+func SetStylesFromPalette(palette NamedColors) {
+	Styles = Styles.WithPalette(palette)
+}
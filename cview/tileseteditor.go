@@ -0,0 +1,562 @@
+package cview
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/memmaker/go/core"
+	"github.com/memmaker/go/recfile"
+	"github.com/memmaker/go/textiles"
+)
+
+// TileSetEditor is a primitive to browse and edit a tile set or a set of
+// icon records. It shows a list of all entries with a preview of their glyph
+// in palette colors, a form to edit the selected entry and a glyph picker.
+//
+// Keyboard navigation: Tab in the list moves to the form, Escape in the form
+// moves back to the list. The "Pick glyph" button moves to the glyph picker,
+// where Enter picks the glyph under the cursor and PageUp/PageDown switch
+// between glyph blocks.
+type TileSetEditor struct {
+	*Flex
+
+	// The entries being edited.
+	records []textiles.IconRecord
+
+	// The index of the selected entry.
+	current int
+
+	// The palette used for previews and the color pickers.
+	palette textiles.ColorPalette
+
+	// The name of the field currently typed into the "New field" input.
+	newFieldName string
+
+	// The file written by the "Save" button.
+	filename string
+
+	list    *List
+	preview *Box
+	form    *Form
+	picker  *GlyphPicker
+
+	// The focus delegate handed to Focus, used to move the focus between
+	// the child primitives.
+	delegate func(p Primitive)
+
+	// An optional function which is called when an entry was changed.
+	changed func(index int, record textiles.IconRecord)
+
+	// An optional function which is called after the "Save" button was used.
+	saved func(err error)
+
+	sync.RWMutex
+}
+
+// NewTileSetEditor returns a new, empty tile set editor which uses the given
+// palette for previews and color pickers.
+func NewTileSetEditor(palette textiles.ColorPalette) *TileSetEditor {
+	e := &TileSetEditor{
+		Flex:    NewFlex(),
+		palette: palette,
+		list:    NewList(),
+		preview: NewBox(),
+		form:    NewForm(),
+		picker:  NewGlyphPicker(),
+	}
+
+	e.list.SetBorder(true)
+	e.list.SetTitle("Tiles")
+	e.list.SetChangedFunc(func(index int, item *ListItem) {
+		e.selectRecord(index)
+	})
+	e.list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if HitShortcut(event, Keys.MoveNextField) {
+			e.focus(e.form)
+			return nil
+		}
+		return event
+	})
+
+	e.preview.SetBorder(true)
+	e.preview.SetTitle("Preview")
+	e.preview.SetDrawFunc(e.drawPreview)
+
+	e.form.SetBorder(true)
+	e.form.SetTitle("Properties")
+	e.form.SetCancelFunc(func() {
+		e.focus(e.list)
+	})
+
+	e.picker.SetBorder(true)
+	e.picker.SetTitle("Glyph")
+	e.picker.SetSelectedFunc(func(glyph rune) {
+		e.setGlyph(glyph)
+		e.focus(e.form)
+	})
+	e.picker.SetDoneFunc(func(key tcell.Key) {
+		e.focus(e.form)
+	})
+
+	details := NewFlex()
+	details.SetDirection(FlexRow)
+	details.AddItem(e.preview, 5, 0, false)
+	details.AddItem(e.form, 0, 1, false)
+	details.AddItem(e.picker, 12, 0, false)
+
+	e.Flex.AddItem(e.list, 28, 0, true)
+	e.Flex.AddItem(details, 0, 1, false)
+	e.rebuild()
+	return e
+}
+
+// SetIconRecords sets the entries to edit.
+func (e *TileSetEditor) SetIconRecords(records []textiles.IconRecord) {
+	e.Lock()
+	e.records = make([]textiles.IconRecord, len(records))
+	copy(e.records, records)
+	e.current = 0
+	e.Unlock()
+
+	e.rebuild()
+}
+
+// SetIconRecordMap sets the entries to edit, sorted by name.
+func (e *TileSetEditor) SetIconRecordMap(records map[string]textiles.IconRecord) {
+	names := make([]string, 0, len(records))
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := make([]textiles.IconRecord, len(names))
+	for i, name := range names {
+		sorted[i] = records[name]
+	}
+	e.SetIconRecords(sorted)
+}
+
+// SetTiles sets the tiles to edit. See textiles.TextTile.ToIconRecord.
+func (e *TileSetEditor) SetTiles(tiles []textiles.TextTile) {
+	records := make([]textiles.IconRecord, len(tiles))
+	for i, tile := range tiles {
+		records[i] = tile.ToIconRecord()
+	}
+	e.SetIconRecords(records)
+}
+
+// GetIconRecords returns a copy of the edited entries.
+func (e *TileSetEditor) GetIconRecords() []textiles.IconRecord {
+	e.RLock()
+	defer e.RUnlock()
+
+	records := make([]textiles.IconRecord, len(e.records))
+	copy(records, e.records)
+	return records
+}
+
+// GetIconRecordMap returns the edited entries keyed by name.
+func (e *TileSetEditor) GetIconRecordMap() map[string]textiles.IconRecord {
+	records := e.GetIconRecords()
+	recordMap := make(map[string]textiles.IconRecord, len(records))
+	for _, record := range records {
+		recordMap[record.Name] = record
+	}
+	return recordMap
+}
+
+// GetTiles returns the edited entries as tiles, resolved in the editor's
+// palette.
+func (e *TileSetEditor) GetTiles() []textiles.TextTile {
+	records := e.GetIconRecords()
+	palette := e.GetPalette()
+	tiles := make([]textiles.TextTile, len(records))
+	for i, record := range records {
+		tiles[i] = textiles.NewTileFromIconRecord(record, palette)
+	}
+	return tiles
+}
+
+// SetPalette sets the palette used for previews and the color pickers.
+func (e *TileSetEditor) SetPalette(palette textiles.ColorPalette) {
+	e.Lock()
+	e.palette = palette
+	e.Unlock()
+
+	e.rebuild()
+}
+
+// GetPalette returns the palette used for previews and the color pickers.
+func (e *TileSetEditor) GetPalette() textiles.ColorPalette {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.palette
+}
+
+// SetFilename sets the file written by the "Save" button. The button is only
+// shown if a filename is set.
+func (e *TileSetEditor) SetFilename(filename string) {
+	e.Lock()
+	e.filename = filename
+	e.Unlock()
+
+	e.rebuildForm()
+}
+
+// SetChangedFunc sets a handler which is called when the user changed an
+// entry.
+func (e *TileSetEditor) SetChangedFunc(handler func(index int, record textiles.IconRecord)) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.changed = handler
+}
+
+// SetSavedFunc sets a handler which is called after the "Save" button wrote
+// the file, with the error that occurred, if any.
+func (e *TileSetEditor) SetSavedFunc(handler func(err error)) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.saved = handler
+}
+
+// Save writes the entries using textiles.WriteIconRecords.
+func (e *TileSetEditor) Save(writer io.StringWriter) error {
+	return textiles.WriteIconRecords(writer, e.GetIconRecords())
+}
+
+// SaveFile writes the entries to a file using textiles.WriteIconRecords.
+func (e *TileSetEditor) SaveFile(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return e.Save(file)
+}
+
+// Focus is called when this primitive receives focus.
+func (e *TileSetEditor) Focus(delegate func(p Primitive)) {
+	e.Lock()
+	e.delegate = delegate
+	e.Unlock()
+
+	delegate(e.list)
+}
+
+func (e *TileSetEditor) focus(p Primitive) {
+	e.RLock()
+	delegate := e.delegate
+	e.RUnlock()
+
+	if delegate != nil {
+		delegate(p)
+	}
+}
+
+func (e *TileSetEditor) selectRecord(index int) {
+	e.Lock()
+	if index < 0 || index >= len(e.records) || index == e.current {
+		e.Unlock()
+		return
+	}
+	e.current = index
+	e.Unlock()
+
+	e.rebuildForm()
+}
+
+// update applies a change to the selected entry.
+func (e *TileSetEditor) update(change func(record *textiles.IconRecord)) {
+	e.Lock()
+	if e.current >= len(e.records) {
+		e.Unlock()
+		return
+	}
+	change(&e.records[e.current])
+	index, record, palette, changed := e.current, e.records[e.current], e.palette, e.changed
+	e.Unlock()
+
+	e.list.SetItemText(index, listItemText(record, palette), "")
+	if changed != nil {
+		changed(index, record)
+	}
+}
+
+func (e *TileSetEditor) setGlyph(glyph rune) {
+	if field, ok := e.form.GetFormItemByLabel("Glyph").(*InputField); ok {
+		field.SetText(string(glyph))
+	}
+	e.update(func(record *textiles.IconRecord) {
+		record.Icon = record.Icon.WithGrapheme(string(glyph))
+	})
+	e.updatePickerColors()
+}
+
+// rebuild recreates the list and the form from the entries.
+func (e *TileSetEditor) rebuild() {
+	e.RLock()
+	texts := make([]string, len(e.records))
+	for i, record := range e.records {
+		texts[i] = listItemText(record, e.palette)
+	}
+	current := e.current
+	e.RUnlock()
+
+	e.list.Clear()
+	for _, text := range texts {
+		e.list.AddItem(NewListItem(text))
+	}
+	if current < len(texts) {
+		e.list.SetCurrentItem(current)
+	}
+	e.rebuildForm()
+}
+
+// rebuildForm recreates the form fields for the selected entry.
+func (e *TileSetEditor) rebuildForm() {
+	e.RLock()
+	palette, filename := e.palette, e.filename
+	var record textiles.IconRecord
+	hasRecord := e.current < len(e.records)
+	if hasRecord {
+		record = e.records[e.current]
+	}
+	e.RUnlock()
+
+	e.form.Clear(true)
+	if hasRecord {
+		e.form.AddInputField("Name", record.Name, 0, nil, func(text string) {
+			e.update(func(record *textiles.IconRecord) {
+				record.Name = text
+			})
+		})
+		e.form.AddInputField("Glyph", record.Icon.Text(), 4, nil, func(text string) {
+			if text == "" {
+				return
+			}
+			cluster := textiles.FirstGrapheme(text)
+			e.update(func(record *textiles.IconRecord) {
+				record.Icon = record.Icon.WithGrapheme(cluster)
+			})
+			e.updatePickerColors()
+		})
+		// The handlers are set after the initial selection, which would call
+		// them and change the entry otherwise.
+		fgOptions, fgIndex := colorOptions(palette, record.Icon.Fg, false)
+		e.form.AddDropDown("Foreground", fgIndex, nil, fgOptions)
+		e.setColorFunc("Foreground", func(record *textiles.IconRecord, name string) {
+			record.Icon.Fg = name
+		})
+		bgOptions, bgIndex := colorOptions(palette, record.Icon.Bg, true)
+		e.form.AddDropDown("Background", bgIndex, nil, bgOptions)
+		e.setColorFunc("Background", func(record *textiles.IconRecord, name string) {
+			record.Icon.Bg = name
+		})
+		for i, field := range record.Meta {
+			fieldIndex := i
+			e.form.AddInputField(field.Name, field.Value, 0, nil, func(text string) {
+				e.update(func(record *textiles.IconRecord) {
+					if fieldIndex < len(record.Meta) {
+						record.Meta[fieldIndex].Value = text
+					}
+				})
+			})
+		}
+		e.form.AddInputField("New field", "", 0, nil, func(text string) {
+			e.Lock()
+			e.newFieldName = text
+			e.Unlock()
+		})
+		e.form.AddButton("Add field", e.addField)
+		e.form.AddButton("Pick glyph", func() {
+			// The glyph may have been edited since the form was built.
+			e.RLock()
+			var glyph rune
+			if e.current < len(e.records) {
+				glyph = e.records[e.current].Icon.Char
+			}
+			e.RUnlock()
+			e.picker.SetCurrentGlyph(glyph)
+			e.focus(e.picker)
+		})
+		e.form.AddButton("Delete", e.deleteRecord)
+	}
+	e.form.AddButton("New", e.newRecord)
+	if filename != "" {
+		e.form.AddButton("Save", func() {
+			err := e.SaveFile(filename)
+			e.RLock()
+			saved := e.saved
+			e.RUnlock()
+			if saved != nil {
+				saved(err)
+			}
+		})
+	}
+	e.updatePickerColors()
+}
+
+// setColorFunc sets the handler of the color drop down with the given label,
+// which sets a color of the selected entry.
+func (e *TileSetEditor) setColorFunc(label string, set func(record *textiles.IconRecord, name string)) {
+	dropDown, ok := e.form.GetFormItemByLabel(label).(*DropDown)
+	if !ok {
+		return
+	}
+	dropDown.SetSelectedFunc(func(index int, option *DropDownOption) {
+		if option == nil {
+			return
+		}
+		name, _ := option.GetReference().(string)
+		e.update(func(record *textiles.IconRecord) {
+			set(record, name)
+		})
+		e.updatePickerColors()
+	})
+}
+
+func (e *TileSetEditor) updatePickerColors() {
+	e.RLock()
+	defer e.RUnlock()
+
+	if e.current >= len(e.records) {
+		return
+	}
+	fg, bg := iconColors(e.records[e.current].Icon, e.palette)
+	e.picker.SetGlyphColors(fg, bg)
+}
+
+func (e *TileSetEditor) addField() {
+	e.Lock()
+	name := e.newFieldName
+	e.newFieldName = ""
+	e.Unlock()
+
+	if name == "" {
+		return
+	}
+	e.update(func(record *textiles.IconRecord) {
+		record.Meta = append(record.Meta, recfile.Field{Name: name})
+	})
+	e.rebuildForm()
+	e.focus(e.form)
+}
+
+func (e *TileSetEditor) newRecord() {
+	e.Lock()
+	e.records = append(e.records, textiles.IconRecord{
+		Name: fmt.Sprintf("tile_%d", len(e.records)),
+		Icon: core.NamedColorChar{Char: '?'},
+	})
+	e.current = len(e.records) - 1
+	e.Unlock()
+
+	e.rebuild()
+	e.focus(e.form)
+}
+
+func (e *TileSetEditor) deleteRecord() {
+	e.Lock()
+	if e.current >= len(e.records) {
+		e.Unlock()
+		return
+	}
+	e.records = append(e.records[:e.current], e.records[e.current+1:]...)
+	if e.current > 0 && e.current >= len(e.records) {
+		e.current--
+	}
+	e.Unlock()
+
+	e.rebuild()
+	e.focus(e.list)
+}
+
+// drawPreview draws the selected glyph as a small patch, as it would look
+// when tiled on a map.
+func (e *TileSetEditor) drawPreview(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+	e.RLock()
+	defer e.RUnlock()
+
+	innerX, innerY, innerWidth, innerHeight := x+1, y+1, width-2, height-2
+	if e.current >= len(e.records) {
+		return innerX, innerY, innerWidth, innerHeight
+	}
+	record := e.records[e.current]
+	fg, bg := iconColors(record.Icon, e.palette)
+	style := tcell.StyleDefault.Foreground(fg).Background(bg)
+	icon := textiles.TextIcon{Char: record.Icon.Char, Grapheme: record.Icon.Grapheme}
+	mainc, combc := icon.Runes()
+	glyphWidth := icon.Width()
+	for row := 0; row < innerHeight; row++ {
+		for column := 0; column+glyphWidth <= 5 && column+glyphWidth <= innerWidth; column += glyphWidth {
+			screen.SetContent(innerX+column, innerY+row, mainc, combc, style)
+		}
+	}
+	if innerWidth > 7 && innerHeight > 0 {
+		Print(screen, []byte(Escape(record.Name)), innerX+7, innerY, innerWidth-7, AlignLeft, Styles.PrimaryTextColor)
+	}
+	return innerX, innerY, innerWidth, innerHeight
+}
+
+func listItemText(record textiles.IconRecord, palette textiles.ColorPalette) string {
+	fg, bg := iconColors(record.Icon, palette)
+	return fmt.Sprintf("[%s:%s]%s[-:-] %s", ColorHex(fg), ColorHex(bg), Escape(record.Icon.Text()), Escape(record.Name))
+}
+
+// colorOptions returns one drop down option per palette color, showing a
+// swatch, and the index of the option of the given color name. Names are
+// matched ignoring case, as in textiles.ColorPalette.Get. A name which is not
+// in the palette gets its own option, so that it is kept until another color
+// is selected.
+func colorOptions(palette textiles.ColorPalette, selectedName string, withNone bool) ([]*DropDownOption, int) {
+	var options []*DropDownOption
+	selected := -1
+	if withNone {
+		none := NewDropDownOption("(none)")
+		none.SetReference("")
+		options = append(options, none)
+		if selectedName == "" {
+			selected = 0
+		}
+	}
+	for _, namedColor := range palette.AsNamedColors() {
+		option := NewDropDownOption(fmt.Sprintf("[%s]██[-] %s", ColorHex(rgbaToColor(namedColor.Color)), Escape(namedColor.Name)))
+		option.SetReference(namedColor.Name)
+		if selected < 0 && strings.EqualFold(namedColor.Name, selectedName) {
+			selected = len(options)
+		}
+		options = append(options, option)
+	}
+	if selected < 0 {
+		text := "(none)"
+		if selectedName != "" {
+			text = fmt.Sprintf("%s (not in palette)", Escape(selectedName))
+		}
+		option := NewDropDownOption(text)
+		option.SetReference(selectedName)
+		selected = len(options)
+		options = append(options, option)
+	}
+	return options, selected
+}
+
+func iconColors(icon core.NamedColorChar, palette textiles.ColorPalette) (tcell.Color, tcell.Color) {
+	fg := rgbaToColor(palette.Get(icon.Fg))
+	bg := Styles.PrimitiveBackgroundColor
+	if icon.HasBackground() {
+		bg = rgbaToColor(palette.Get(icon.Bg))
+	}
+	return fg, bg
+}
+
+func rgbaToColor(c color.RGBA) tcell.Color {
+	return tcell.NewRGBColor(int32(c.R), int32(c.G), int32(c.B))
+}
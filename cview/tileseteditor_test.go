@@ -0,0 +1,95 @@
+package cview
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/memmaker/go/core"
+	"github.com/memmaker/go/recfile"
+	"github.com/memmaker/go/textiles"
+)
+
+func testTileSetRecords() []textiles.IconRecord {
+	return []textiles.IconRecord{
+		{Name: "wall", Icon: core.NamedColorChar{Char: '#', Fg: "White", Bg: "magenta"}},
+		{Name: "floor", Icon: core.NamedColorChar{Char: '.', Fg: "black"}},
+		{Name: "tree", Icon: core.NamedColorChar{Char: '♣'}, Meta: []recfile.Field{{Name: "Blocks", Value: "true"}}},
+	}
+}
+
+func TestTileSetEditorLoad(t *testing.T) {
+	t.Parallel()
+
+	palettes := []textiles.ColorPalette{
+		textiles.NewPaletteFromNamedColors([]textiles.NamedColor{
+			{Name: "black", Color: color.RGBA{A: 255}},
+			{Name: "white", Color: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		}),
+		textiles.NewPaletteFromNamedColors(nil),
+	}
+	for _, palette := range palettes {
+		changes := 0
+		e := NewTileSetEditor(palette)
+		e.SetChangedFunc(func(index int, record textiles.IconRecord) {
+			changes++
+		})
+		e.SetFilename("tiles.rec")
+		e.SetIconRecords(testTileSetRecords())
+		for i := range testTileSetRecords() {
+			e.list.SetCurrentItem(i)
+		}
+		e.SetPalette(palette)
+
+		if changes != 0 {
+			t.Errorf("failed to load TileSetEditor: expected no changes, got %d", changes)
+		}
+		if records := e.GetIconRecords(); !reflect.DeepEqual(records, testTileSetRecords()) {
+			t.Errorf("failed to load TileSetEditor: expected records %v, got %v", testTileSetRecords(), records)
+		}
+
+		app, err := newTestApp(e)
+		if err != nil {
+			t.Errorf("failed to initialize Application: %s", err)
+		}
+		e.Draw(app.screen)
+	}
+}
+
+func TestTileSetEditorSelectColor(t *testing.T) {
+	t.Parallel()
+
+	e := NewTileSetEditor(textiles.NewDefaultPalette())
+	var changed textiles.IconRecord
+	e.SetChangedFunc(func(index int, record textiles.IconRecord) {
+		changed = record
+	})
+	e.SetIconRecords(testTileSetRecords())
+
+	foreground, ok := e.form.GetFormItemByLabel("Foreground").(*DropDown)
+	if !ok {
+		t.Fatalf("failed to find foreground drop down")
+	}
+	if index, option := foreground.GetCurrentOption(); option == nil || option.GetReference() != "white" {
+		t.Errorf("failed to select foreground: expected white, got option %d", index)
+	}
+	foreground.SetCurrentOption(2)
+	if changed.Icon.Fg != "red" {
+		t.Errorf("failed to change foreground: expected red, got %s", changed.Icon.Fg)
+	}
+
+	background, ok := e.form.GetFormItemByLabel("Background").(*DropDown)
+	if !ok {
+		t.Fatalf("failed to find background drop down")
+	}
+	if _, option := background.GetCurrentOption(); option == nil || option.GetReference() != "magenta" {
+		t.Errorf("failed to select background: expected an option for magenta")
+	}
+	background.SetCurrentOption(0)
+	if changed.Icon.Bg != "" {
+		t.Errorf("failed to change background: expected none, got %s", changed.Icon.Bg)
+	}
+	if records := e.GetIconRecords(); records[0].Icon.Fg != "red" || records[0].Icon.Bg != "" {
+		t.Errorf("failed to change colors: got %v", records[0].Icon)
+	}
+}
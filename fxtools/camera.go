@@ -0,0 +1,249 @@
+package fxtools
+
+import (
+	"github.com/memmaker/go/geometry"
+	"math"
+	"math/rand"
+)
+
+// CameraController moves a geometry.Camera smoothly towards a followed
+// position, with a dead zone in which the position can move without moving
+// the camera, screen shake, zoom factors for glyphs wider than one screen
+// cell, and letterboxing of maps smaller than the viewport.
+//
+// Follow and Shake are called when the game state changes, and Update once
+// per frame with the elapsed time, which updates the camera's viewport.
+type CameraController struct {
+	Camera  *geometry.Camera
+	MapSize geometry.Point
+
+	// DeadZone is the size of the rectangle around the view center in which
+	// the followed position can move without moving the camera.
+	DeadZone geometry.Point
+	// FollowTime is the time in seconds taken to reach a new view center.
+	FollowTime float64
+	// Easing shapes the movement towards a new view center, for example
+	// EaseOutQuad.
+	Easing func(float64) float64
+
+	// MaxShake is the largest shake offset, in map cells.
+	MaxShake float64
+	// ShakeDecay is the trauma lost per second.
+	ShakeDecay float64
+	// Random is used for shake offsets, if not nil.
+	Random *rand.Rand
+
+	screenSize geometry.Point
+	zoom       geometry.Point
+	start      geometry.PointF
+	center     geometry.PointF
+	goal       geometry.PointF
+	elapsed    float64
+	trauma     float64
+	shake      geometry.Point
+}
+
+// NewCameraController returns a controller for a camera on a map of a given
+// size. The screen size is the size of the camera's current viewport.
+func NewCameraController(camera *geometry.Camera, mapSize geometry.Point) *CameraController {
+	c := &CameraController{
+		Camera:     camera,
+		MapSize:    mapSize,
+		FollowTime: 0.25,
+		Easing:     EaseOutQuad,
+		MaxShake:   2,
+		ShakeDecay: 1.5,
+		screenSize: camera.ViewPort.Size(),
+		zoom:       geometry.Point{X: 1, Y: 1},
+	}
+	c.center = c.clampCenter(camera.ViewPort.Min.ToPointF().Add(c.ViewSize().ToPointF().Div(2)))
+	c.start, c.goal = c.center, c.center
+	c.apply()
+	return c
+}
+
+// SetScreenSize sets the size of the view on screen, in screen cells.
+func (c *CameraController) SetScreenSize(size geometry.Point) {
+	c.screenSize = size
+	c.goal = c.clampCenter(c.goal)
+	c.apply()
+}
+
+// SetZoom sets the number of screen cells used by each map cell, for
+// example 2 columns for square glyphs made of two half-width characters.
+func (c *CameraController) SetZoom(zoom geometry.Point) {
+	c.zoom = geometry.Point{X: max(1, zoom.X), Y: max(1, zoom.Y)}
+	c.goal = c.clampCenter(c.goal)
+	c.apply()
+}
+
+// SetHalfWidth sets a horizontal zoom of 2 when on, as used by
+// Rect.ToHalfWidth and Point.ToHalfWidth, and no zoom otherwise.
+func (c *CameraController) SetHalfWidth(on bool) {
+	if on {
+		c.SetZoom(geometry.Point{X: 2, Y: 1})
+	} else {
+		c.SetZoom(geometry.Point{X: 1, Y: 1})
+	}
+}
+
+// ViewSize returns the size of the view, in map cells.
+func (c *CameraController) ViewSize() geometry.Point {
+	return geometry.Point{X: c.screenSize.X / c.zoom.X, Y: c.screenSize.Y / c.zoom.Y}
+}
+
+// Follow moves the camera towards a position if it is out of the dead
+// zone. The movement takes place during the next updates.
+func (c *CameraController) Follow(target geometry.Point) {
+	tf := target.ToCenteredPointF()
+	goal := c.goal
+	half := c.DeadZone.ToPointF().Div(2)
+	if tf.X > goal.X+half.X {
+		goal.X = tf.X - half.X
+	} else if tf.X < goal.X-half.X {
+		goal.X = tf.X + half.X
+	}
+	if tf.Y > goal.Y+half.Y {
+		goal.Y = tf.Y - half.Y
+	} else if tf.Y < goal.Y-half.Y {
+		goal.Y = tf.Y + half.Y
+	}
+	goal = c.clampCenter(goal)
+	if goal == c.goal {
+		return
+	}
+	c.start = c.center
+	c.goal = goal
+	c.elapsed = 0
+}
+
+// Snap centers the camera on a position immediately.
+func (c *CameraController) Snap(target geometry.Point) {
+	c.goal = c.clampCenter(target.ToCenteredPointF())
+	c.start, c.center = c.goal, c.goal
+	c.elapsed = 0
+	c.apply()
+}
+
+// Shake adds trauma to the camera, from 0 to 1. The shake offset grows with
+// the square of the trauma, which decays over time.
+func (c *CameraController) Shake(trauma float64) {
+	c.trauma = Clamp(0, 1, c.trauma+trauma)
+}
+
+// Trauma returns the current shake trauma.
+func (c *CameraController) Trauma() float64 {
+	return c.trauma
+}
+
+// IsMoving returns true while the camera has not reached its goal or shakes.
+func (c *CameraController) IsMoving() bool {
+	return c.center != c.goal || c.trauma > 0
+}
+
+// Update advances the camera movement and shake by dt seconds, and updates
+// the camera's viewport.
+func (c *CameraController) Update(dt float64) {
+	c.elapsed += dt
+	t := 1.0
+	if c.FollowTime > 0 {
+		t = math.Min(1, c.elapsed/c.FollowTime)
+	}
+	if t >= 1 {
+		c.center = c.goal
+	} else {
+		ease := c.Easing
+		if ease == nil {
+			ease = EaseLinear
+		}
+		c.center = LerpPointF(c.start, c.goal, ease(t))
+	}
+	c.trauma = math.Max(0, c.trauma-c.ShakeDecay*dt)
+	c.shake = geometry.Point{}
+	if c.trauma > 0 {
+		amount := c.MaxShake * c.trauma * c.trauma
+		c.shake = geometry.Point{
+			X: int(math.Round(amount * (2*c.random() - 1))),
+			Y: int(math.Round(amount * (2*c.random() - 1))),
+		}
+	}
+	c.apply()
+}
+
+func (c *CameraController) random() float64 {
+	if c.Random != nil {
+		return c.Random.Float64()
+	}
+	return rand.Float64()
+}
+
+// clampCenter limits a view center so that the view stays on the map, or
+// centers the map on axes where it is smaller than the view (letterboxing).
+// Wrapping axes are not limited.
+func (c *CameraController) clampCenter(center geometry.PointF) geometry.PointF {
+	view := c.ViewSize().ToPointF()
+	size := c.MapSize.ToPointF()
+	if !c.Camera.Wrap.WrapX {
+		center.X = clampAxis(center.X, view.X, size.X)
+	}
+	if !c.Camera.Wrap.WrapY {
+		center.Y = clampAxis(center.Y, view.Y, size.Y)
+	}
+	return center
+}
+
+func clampAxis(center, view, size float64) float64 {
+	if size <= view {
+		return size / 2
+	}
+	return Clamp(view/2, size-view/2, center)
+}
+
+// apply sets the camera's viewport from the current view center and shake.
+func (c *CameraController) apply() {
+	view := c.ViewSize()
+	min := geometry.Point{
+		X: int(math.Floor(c.center.X - float64(view.X)/2 + 0.5)),
+		Y: int(math.Floor(c.center.Y - float64(view.Y)/2 + 0.5)),
+	}
+	min = c.Camera.Wrap.Wrap(min.Add(c.shake))
+	c.Camera.ViewPort = geometry.Rect{Min: min, Max: min.Add(view)}
+}
+
+// IsLetterboxed returns true on each axis where the map is smaller than the
+// view, so that there are empty borders around it.
+func (c *CameraController) IsLetterboxed() (x, y bool) {
+	view := c.ViewSize()
+	return !c.Camera.Wrap.WrapX && c.MapSize.X < view.X, !c.Camera.Wrap.WrapY && c.MapSize.Y < view.Y
+}
+
+// ScreenRect returns the camera's viewport in screen cells, taking the zoom
+// into account, as Rect.ToHalfWidth does for half-width glyphs.
+func (c *CameraController) ScreenRect() geometry.Rect {
+	vp := c.Camera.ViewPort
+	return geometry.Rect{
+		Min: geometry.Point{X: vp.Min.X * c.zoom.X, Y: vp.Min.Y * c.zoom.Y},
+		Max: geometry.Point{X: vp.Max.X * c.zoom.X, Y: vp.Max.Y * c.zoom.Y},
+	}
+}
+
+// WorldToScreen returns the screen cell of the top left corner of a map
+// position, relative to the view.
+func (c *CameraController) WorldToScreen(p geometry.Point) geometry.Point {
+	s := c.Camera.WorldToScreen(p)
+	return geometry.Point{X: s.X * c.zoom.X, Y: s.Y * c.zoom.Y}
+}
+
+// ScreenToWorld returns the map position under a screen cell, relative to
+// the view.
+func (c *CameraController) ScreenToWorld(s geometry.Point) geometry.Point {
+	return c.Camera.ScreenToWorld(geometry.Point{X: floorDiv(s.X, c.zoom.X), Y: floorDiv(s.Y, c.zoom.Y)})
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
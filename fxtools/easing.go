@@ -15,3 +15,29 @@ func EaseInExpo(x float64) float64 {
     }
     return math.Pow(2, 10*x-10)
 }
+
+func EaseLinear(x float64) float64 {
+    return x
+}
+
+func EaseOutQuad(x float64) float64 {
+    return 1 - (1-x)*(1-x)
+}
+
+func EaseInOutQuad(x float64) float64 {
+    if x < 0.5 {
+        return 2 * x * x
+    }
+    return 1 - math.Pow(-2*x+2, 2)/2
+}
+
+func EaseOutCubic(x float64) float64 {
+    return 1 - math.Pow(1-x, 3)
+}
+
+func EaseOutExpo(x float64) float64 {
+    if x == 1 {
+        return 1
+    }
+    return 1 - math.Pow(2, -10*x)
+}
@@ -0,0 +1,194 @@
+// Package aoe implements targeting shapes for projectiles and area of
+// effect abilities: lines, beams, bolts, cones, bursts, chains and bouncing
+// rays. All shapes stop at impassable positions, and return the affected
+// positions in order, as they would be reached by the effect.
+package aoe
+
+import (
+	"github.com/memmaker/go/geometry"
+	"sort"
+)
+
+// Area describes where effects can go.
+type Area struct {
+	// Range is the range of valid positions, for example the map bounds.
+	Range geometry.Rect
+
+	// Passable returns true for positions that effects pass through. A
+	// nil Passable allows all positions. An impassable position stops
+	// lines and blocks bursts, but is itself affected, for example a wall
+	// hit by a fire bolt.
+	Passable func(p geometry.Point) bool
+
+	// Visible optionally restricts effects to a field of view, for example
+	// the player's FOV.Visible. Lines stop before the first position which
+	// is not visible, and areas leave out positions which are not visible.
+	Visible func(p geometry.Point) bool
+}
+
+// NewArea returns an area for a range, with a passability predicate.
+func NewArea(rg geometry.Rect, passable func(p geometry.Point) bool) Area {
+	return Area{Range: rg, Passable: passable}
+}
+
+// WithFOV returns a copy of the area restricted to visible positions.
+func (a Area) WithFOV(visible func(p geometry.Point) bool) Area {
+	a.Visible = visible
+	return a
+}
+
+func (a Area) isPassable(p geometry.Point) bool {
+	return p.In(a.Range) && (a.Passable == nil || a.Passable(p))
+}
+
+func (a Area) isVisible(p geometry.Point) bool {
+	return a.Visible == nil || a.Visible(p)
+}
+
+// ray returns the positions on the line from an origin through a target,
+// excluding the origin, until length positions, an impassable position
+// (included) or an invisible position (excluded). If stop is not nil, the
+// ray also ends after a position for which it returns true.
+func (a Area) ray(from, to geometry.Point, length int, stop func(p geometry.Point) bool) []geometry.Point {
+	if from == to || length <= 0 {
+		return nil
+	}
+	end := to
+	d := to.Sub(from)
+	if steps := max(geometry.Abs(d.X), geometry.Abs(d.Y)); steps < length {
+		k := (length + steps - 1) / steps
+		end = from.Add(d.Mul(k))
+	}
+	var ps []geometry.Point
+	geometry.Bresenham(from.X, from.Y, end.X, end.Y, func(x, y int) bool {
+		p := geometry.Point{X: x, Y: y}
+		if p == from {
+			return true
+		}
+		if !p.In(a.Range) || !a.isVisible(p) {
+			return false
+		}
+		ps = append(ps, p)
+		if len(ps) >= length || !a.isPassable(p) {
+			return false
+		}
+		return stop == nil || !stop(p)
+	})
+	return ps
+}
+
+// Line returns the positions on the line from an origin to a target,
+// excluding the origin and including the target, unless the line is stopped
+// earlier by an impassable position.
+func (a Area) Line(from, to geometry.Point) []geometry.Point {
+	d := to.Sub(from)
+	return a.ray(from, to, max(geometry.Abs(d.X), geometry.Abs(d.Y)), nil)
+}
+
+// Beam returns the positions on the line from an origin through a target,
+// continuing beyond the target, until length positions or an impassable
+// position.
+func (a Area) Beam(from, to geometry.Point, length int) []geometry.Point {
+	return a.ray(from, to, length, nil)
+}
+
+// Bolt returns the positions on the line from an origin through a target
+// flown over by a projectile, like Beam, which also stops at the targets it
+// hits, as given by isTarget, after piercing through pierce targets. The
+// last position is the impact position.
+func (a Area) Bolt(from, to geometry.Point, length, pierce int, isTarget func(p geometry.Point) bool) []geometry.Point {
+	hits := 0
+	return a.ray(from, to, length, func(p geometry.Point) bool {
+		if isTarget == nil || !isTarget(p) {
+			return false
+		}
+		hits++
+		return hits > pierce
+	})
+}
+
+// inSight returns true if the line from an origin to a position is not
+// blocked by an impassable position before reaching it.
+func (a Area) inSight(from, p geometry.Point) bool {
+	los := geometry.LineOfSight(from, p, func(q geometry.Point) bool {
+		return q == from || a.isPassable(q)
+	})
+	return los[len(los)-1] == p
+}
+
+// within returns the positions within a radius of an origin for which keep
+// returns true, and which are in sight of the origin, ordered by distance.
+// The origin is not included.
+func (a Area) within(from geometry.Point, radius int, keep func(p geometry.Point) bool) []geometry.Point {
+	rg := geometry.NewRect(from.X-radius, from.Y-radius, from.X+radius+1, from.Y+radius+1).Intersect(a.Range)
+	var ps []geometry.Point
+	rg.Iter(func(p geometry.Point) {
+		if p == from || int(geometry.Distance(p, from)+0.5) > radius {
+			return
+		}
+		if !a.isVisible(p) || !keep(p) || !a.inSight(from, p) {
+			return
+		}
+		ps = append(ps, p)
+	})
+	sort.SliceStable(ps, func(i, j int) bool {
+		return geometry.DistanceSquared(ps[i], from) < geometry.DistanceSquared(ps[j], from)
+	})
+	return ps
+}
+
+// Burst returns the positions hit by an explosion at a center, within a
+// radius as given by CircleAround, and not shielded from the center by
+// impassable positions. The center comes first, then positions by
+// increasing distance.
+func (a Area) Burst(center geometry.Point, radius int) []geometry.Point {
+	if !center.In(a.Range) {
+		return nil
+	}
+	ps := []geometry.Point{center}
+	if !a.isPassable(center) {
+		return ps
+	}
+	return append(ps, a.within(center, radius, func(geometry.Point) bool { return true })...)
+}
+
+// Cone returns the positions hit by a cone shaped effect from an origin,
+// within a radius, in a direction and angle as described by
+// geometry.VisionCone, ordered by increasing distance. The origin is not
+// included.
+func (a Area) Cone(from geometry.Point, direction geometry.CompassDirection, angle float64, radius int) []geometry.Point {
+	cone := geometry.VisionCone{Direction: direction, Angle: angle}
+	return a.within(from, radius, func(p geometry.Point) bool {
+		return cone.Contains(from, p)
+	})
+}
+
+// ConeTowards is like Cone, in the direction of a target position.
+func (a Area) ConeTowards(from, to geometry.Point, angle float64, radius int) []geometry.Point {
+	direction := geometry.CompassDirection(geometry.DirectionVectorToAngleInDegrees(to.Sub(from)))
+	return a.Cone(from, direction, angle, radius)
+}
+
+// Chain returns the targets hit by a chain effect, such as chain lightning,
+// in order. Starting at an origin, the effect jumps to the nearest target
+// in sight, as given by isTarget, within jumpRange of its last position,
+// which has not been hit yet, at most maxTargets times. Targets at the same
+// distance are chosen top to bottom and left to right. The origin is never
+// a target.
+func (a Area) Chain(from geometry.Point, jumpRange, maxTargets int, isTarget func(p geometry.Point) bool) []geometry.Point {
+	hit := map[geometry.Point]bool{from: true}
+	var targets []geometry.Point
+	current := from
+	for len(targets) < maxTargets {
+		candidates := a.within(current, jumpRange, func(p geometry.Point) bool {
+			return !hit[p] && isTarget(p)
+		})
+		if len(candidates) == 0 {
+			break
+		}
+		current = candidates[0]
+		hit[current] = true
+		targets = append(targets, current)
+	}
+	return targets
+}
@@ -0,0 +1,47 @@
+package aoe
+
+import (
+	"github.com/memmaker/go/fxtools"
+	"github.com/memmaker/go/geometry"
+	"math"
+)
+
+// Bounce returns the positions traversed by a ray cast from the center of
+// an origin through the center of a target, which bounces off impassable
+// positions up to bounces times, until length positions. The origin is not
+// included, and neither are the positions the ray bounced off.
+//
+// Rays are cast with fxtools.ReflectingRaycast2D, so they are not limited to
+// the eight directions of Line and Beam.
+func (a Area) Bounce(from, to geometry.Point, length, bounces int) []geometry.Point {
+	if from == to || length <= 0 || !a.isPassable(from) {
+		return nil
+	}
+	dx, dy := float64(to.X-from.X), float64(to.Y-from.Y)
+	norm := math.Hypot(dx, dy)
+	shouldReflect := func(x, y int64) bool {
+		return !a.isPassable(geometry.Point{X: int(x), Y: int(y)})
+	}
+	hits := fxtools.ReflectingRaycast2D(float64(from.X)+0.5, float64(from.Y)+0.5, dx/norm, dy/norm, bounces+1, shouldReflect)
+	var ps []geometry.Point
+	last := from
+	for _, hit := range hits {
+		for _, cell := range hit.TraversedGridCells {
+			p := geometry.Point{X: int(cell[0]), Y: int(cell[1])}
+			// Reflected rays start on the border of the cell they bounced
+			// off, or of the last traversed cell.
+			if p == last || !a.isPassable(p) {
+				continue
+			}
+			if !a.isVisible(p) {
+				return ps
+			}
+			ps = append(ps, p)
+			last = p
+			if len(ps) >= length {
+				return ps
+			}
+		}
+	}
+	return ps
+}
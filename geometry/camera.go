@@ -2,37 +2,56 @@ package geometry
 
 type Camera struct {
 	ViewPort Rect
+	// Wrap is the topology of the map, for maps with wrapping edges. The
+	// viewport is not clamped on wrapping axes, and world positions are
+	// wrapped. The zero value does not wrap.
+	Wrap Topology
 }
 
 func NewCamera(x0, y0, x1, y1 int) *Camera {
 	return &Camera{ViewPort: NewRect(x0, y0, x1, y1)}
 }
 func (c *Camera) WorldToScreen(p Point) Point {
-	return p.Sub(c.ViewPort.Min)
+	screen := p.Sub(c.ViewPort.Min)
+	if c.Wrap.WrapX {
+		screen.X = mod(screen.X, c.Wrap.Size.X)
+	}
+	if c.Wrap.WrapY {
+		screen.Y = mod(screen.Y, c.Wrap.Size.Y)
+	}
+	return screen
 }
 
 func (c *Camera) ScreenToWorld(p Point) Point {
-	return p.Add(c.ViewPort.Min)
+	return c.Wrap.Wrap(p.Add(c.ViewPort.Min))
 }
 func (c *Camera) CenterOn(targetWorldPosition Point, mapWidth int, mapHeight int) {
 	centerOfCameraInWorld := c.ViewPort.Mid()
-	deltaMovement := targetWorldPosition.Sub(centerOfCameraInWorld)
+	deltaMovement := c.Wrap.Delta(centerOfCameraInWorld, targetWorldPosition)
 	c.MoveBy(deltaMovement, mapWidth, mapHeight)
 }
 func (c *Camera) MoveBy(delta Point, mapWidth int, mapHeight int) {
 	deltaMin := c.ViewPort.Min.Add(delta)
 	deltaMax := c.ViewPort.Max.Add(delta)
-	if deltaMin.X < 0 {
-		delta.X = delta.X - deltaMin.X
-	}
-	if deltaMin.Y < 0 {
-		delta.Y = delta.Y - deltaMin.Y
-	}
-	if deltaMax.X > mapWidth {
-		delta.X = delta.X - (deltaMax.X - mapWidth)
+	if !c.Wrap.WrapX {
+		if deltaMin.X < 0 {
+			delta.X = delta.X - deltaMin.X
+		}
+		if deltaMax.X > mapWidth {
+			delta.X = delta.X - (deltaMax.X - mapWidth)
+		}
 	}
-	if deltaMax.Y > mapHeight {
-		delta.Y = delta.Y - (deltaMax.Y - mapHeight)
+	if !c.Wrap.WrapY {
+		if deltaMin.Y < 0 {
+			delta.Y = delta.Y - deltaMin.Y
+		}
+		if deltaMax.Y > mapHeight {
+			delta.Y = delta.Y - (deltaMax.Y - mapHeight)
+		}
 	}
 	c.ViewPort = c.ViewPort.Add(delta)
+	// Keep the viewport origin on the map, so that it does not drift away
+	// on wrapping axes.
+	min := c.Wrap.Wrap(c.ViewPort.Min)
+	c.ViewPort = c.ViewPort.Add(min.Sub(c.ViewPort.Min))
 }
@@ -0,0 +1,216 @@
+package collision
+
+import (
+	"github.com/memmaker/go/fxtools"
+	"github.com/memmaker/go/geometry"
+	"math"
+)
+
+// Hit describes the first collision of a movement with a solid grid cell.
+type Hit struct {
+	Collided bool
+	Time     float64        // fraction of the movement done, 1 without collision
+	Normal   Vec2           // normal of the side of the cell that was hit
+	Cell     geometry.Point // cell that was hit
+}
+
+// skin is the distance by which rays are cast from inside the moving box, and
+// by which cells are shrunk when tested against it, so that cells the box
+// only touches are not hit.
+const skin = 1e-6
+
+// Raycast returns the first solid cell on the way of a position moving by
+// delta. The cell of the origin is not tested, as with fxtools.Raycast2D.
+func Raycast(origin, delta Vec2, solid func(p geometry.Point) bool) Hit {
+	length := delta.Len()
+	if length == 0 {
+		return Hit{Time: 1}
+	}
+	dir := delta.Div(length)
+	// Cells beyond the movement stop the ray, which would go on forever
+	// otherwise.
+	cells := cellsBetween(origin, origin.Add(delta))
+	info := fxtools.Raycast2D(origin.X, origin.Y, dir.X, dir.Y, func(x, y int64) bool {
+		p := geometry.Point{X: int(x), Y: int(y)}
+		return !p.In(cells) || solid(p)
+	})
+	cell := geometry.Point{X: int(info.ColliderGridPosition[0]), Y: int(info.ColliderGridPosition[1])}
+	if !cell.In(cells) {
+		return Hit{Time: 1}
+	}
+	hit := Hit{Collided: true, Cell: cell}
+	switch info.HitSide {
+	case fxtools.East:
+		hit.Normal = Vec2{X: -1}
+		hit.Time = (float64(cell.X) - origin.X) / delta.X
+	case fxtools.West:
+		hit.Normal = Vec2{X: 1}
+		hit.Time = (float64(cell.X+1) - origin.X) / delta.X
+	case fxtools.South:
+		hit.Normal = Vec2{Y: -1}
+		hit.Time = (float64(cell.Y) - origin.Y) / delta.Y
+	case fxtools.North:
+		hit.Normal = Vec2{Y: 1}
+		hit.Time = (float64(cell.Y+1) - origin.Y) / delta.Y
+	}
+	if hit.Time >= 1 {
+		return Hit{Time: 1}
+	}
+	hit.Time = math.Max(0, hit.Time)
+	return hit
+}
+
+// cellsBetween returns the range of the cells of the bounding box of two
+// positions.
+func cellsBetween(v, w Vec2) geometry.Rect {
+	return geometry.Rect{
+		Min: Cell(Vec2{X: math.Min(v.X, w.X), Y: math.Min(v.Y, w.Y)}),
+		Max: Cell(Vec2{X: math.Max(v.X, w.X), Y: math.Max(v.Y, w.Y)}).Add(geometry.Point{X: 1, Y: 1}),
+	}
+}
+
+// SweepAABB moves a box by delta until it hits a solid cell, and returns
+// the moved box and the collision. After a collision, the side of the box
+// touches the cell exactly. Boxes only touching a cell on a side or a
+// corner do not collide with it, so that they can slide along walls.
+//
+// Rays are cast with fxtools.Raycast2D from the leading sides of the box, at
+// most one cell apart, so that no cell can slip between them, and the solid
+// cells they cross are tested against the moving box. A box already
+// overlapping solid cells is not stopped by them.
+func SweepAABB(box AABB, delta Vec2, solid func(p geometry.Point) bool) (AABB, Hit) {
+	best := Hit{Time: 1}
+	test := func(p geometry.Point) {
+		if solid(p) {
+			if t, normal, ok := sweepCell(box, delta, p); ok && t < best.Time {
+				best = Hit{Collided: true, Time: t, Normal: normal, Cell: p}
+			}
+		}
+	}
+	cast := func(start Vec2) {
+		cells := cellsBetween(start, start.Add(delta))
+		dir := delta.Normalize()
+		// The start cell is not tested by the raycast, but the box can touch
+		// it after a collision on the other axis.
+		test(Cell(start))
+		fxtools.Raycast2D(start.X, start.Y, dir.X, dir.Y, func(x, y int64) bool {
+			p := geometry.Point{X: int(x), Y: int(y)}
+			if !p.In(cells) {
+				return true
+			}
+			test(p)
+			return false
+		})
+	}
+	if delta.X != 0 {
+		x := box.Max.X - skin
+		if delta.X < 0 {
+			x = box.Min.X + skin
+		}
+		for _, y := range samples(box.Min.Y, box.Max.Y) {
+			cast(Vec2{X: x, Y: y})
+		}
+	}
+	if delta.Y != 0 {
+		y := box.Max.Y - skin
+		if delta.Y < 0 {
+			y = box.Min.Y + skin
+		}
+		for _, x := range samples(box.Min.X, box.Max.X) {
+			cast(Vec2{X: x, Y: y})
+		}
+	}
+	moved := box.Translate(delta.Mul(best.Time))
+	if !best.Collided {
+		return moved, best
+	}
+	// Rounding errors are removed, so that the box touches the cell.
+	var shift Vec2
+	switch best.Normal {
+	case Vec2{X: -1}:
+		shift.X = float64(best.Cell.X) - moved.Max.X
+	case Vec2{X: 1}:
+		shift.X = float64(best.Cell.X+1) - moved.Min.X
+	case Vec2{Y: -1}:
+		shift.Y = float64(best.Cell.Y) - moved.Max.Y
+	case Vec2{Y: 1}:
+		shift.Y = float64(best.Cell.Y+1) - moved.Min.Y
+	}
+	return moved.Translate(shift), best
+}
+
+// sweepCell returns the fraction of a movement after which a box overlaps a
+// cell, and the normal of the side of the cell it enters through. The cell
+// is shrunk by skin, so that boxes touching it, or passing by one of its
+// corners, do not overlap it.
+func sweepCell(box AABB, delta Vec2, cell geometry.Point) (float64, Vec2, bool) {
+	enter, exit := math.Inf(-1), math.Inf(1)
+	var normal Vec2
+	for axis := 0; axis < 2; axis++ {
+		lo, hi, d, c := box.Min.X, box.Max.X, delta.X, float64(cell.X)
+		if axis == 1 {
+			lo, hi, d, c = box.Min.Y, box.Max.Y, delta.Y, float64(cell.Y)
+		}
+		cmin, cmax := c+skin, c+1-skin
+		if d == 0 {
+			if hi <= cmin || lo >= cmax {
+				return 0, Vec2{}, false
+			}
+			continue
+		}
+		t0, t1 := (cmin-hi)/d, (cmax-lo)/d
+		side := -1.0
+		if d < 0 {
+			t0, t1 = (cmax-lo)/d, (cmin-hi)/d
+			side = 1
+		}
+		if t0 > enter {
+			enter = t0
+			normal = Vec2{}
+			if axis == 0 {
+				normal.X = side
+			} else {
+				normal.Y = side
+			}
+		}
+		exit = math.Min(exit, t1)
+	}
+	if enter >= exit || enter >= 1 || enter < 0 {
+		return 0, Vec2{}, false
+	}
+	return enter, normal, true
+}
+
+// samples returns positions from lo to hi, inset by skin, at most one unit
+// apart.
+func samples(lo, hi float64) []float64 {
+	lo, hi = lo+skin, hi-skin
+	if hi <= lo {
+		return []float64{(lo + hi) / 2}
+	}
+	n := int(math.Ceil(hi - lo))
+	vs := make([]float64, 0, n+1)
+	for i := 0; i <= n; i++ {
+		vs = append(vs, lo+(hi-lo)*float64(i)/float64(n))
+	}
+	return vs
+}
+
+// Slide moves a box by delta like SweepAABB, but after a collision it goes
+// on along the side of the cell that was hit with the rest of the movement,
+// as when walking along a wall. It returns the moved box, and the hits.
+func Slide(box AABB, delta Vec2, solid func(p geometry.Point) bool) (AABB, []Hit) {
+	var hits []Hit
+	// A movement can be stopped once on each axis, plus once by a corner.
+	for i := 0; i < 3 && delta != (Vec2{}); i++ {
+		var hit Hit
+		box, hit = SweepAABB(box, delta, solid)
+		if !hit.Collided {
+			break
+		}
+		hits = append(hits, hit)
+		rest := delta.Mul(1 - hit.Time)
+		delta = rest.Sub(hit.Normal.Mul(rest.Dot(hit.Normal)))
+	}
+	return box, hits
+}
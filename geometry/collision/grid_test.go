@@ -0,0 +1,170 @@
+package collision
+
+import (
+	"github.com/memmaker/go/geometry"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// walls returns a solid function for a set of cells.
+func walls(cells ...geometry.Point) func(p geometry.Point) bool {
+	set := make(map[geometry.Point]bool, len(cells))
+	for _, c := range cells {
+		set[c] = true
+	}
+	return func(p geometry.Point) bool { return set[p] }
+}
+
+func TestSweepAABBCorner(t *testing.T) {
+	// The corner of the box touches the corners of the cells on the way.
+	solid := walls(geometry.Point{X: 2, Y: 0}, geometry.Point{X: 0, Y: 2})
+	box := AABB{Min: V(0, 0), Max: V(1, 1)}
+	moved, hit := SweepAABB(box, V(2, 2), solid)
+	if hit.Collided {
+		t.Errorf("grazing corner: got hit %+v", hit)
+	}
+	if moved != box.Translate(V(2, 2)) {
+		t.Errorf("grazing corner: got box %v", moved)
+	}
+	// The box passes by the corner of the cell, touching its side.
+	solid = walls(geometry.Point{X: 2, Y: 2})
+	box = AABB{Min: V(0, 0), Max: V(1, 2)}
+	if _, hit = SweepAABB(box, V(1, 2), solid); hit.Collided {
+		t.Errorf("touching corner: got hit %+v", hit)
+	}
+	// The box cuts the corner of the cell.
+	box = AABB{Min: V(0, 0.5), Max: V(1, 1.5)}
+	moved, hit = SweepAABB(box, V(2, 2), solid)
+	if !hit.Collided || hit.Cell != (geometry.Point{X: 2, Y: 2}) || hit.Normal != V(-1, 0) {
+		t.Fatalf("cutting corner: got hit %+v", hit)
+	}
+	if moved.Max.X != 2 || math.Abs(moved.Min.Y-1.5) > 1e-5 {
+		t.Errorf("cutting corner: got box %v", moved)
+	}
+}
+
+func TestSweepAABBIntegerEdges(t *testing.T) {
+	solid := walls(geometry.Point{X: 3, Y: 0}, geometry.Point{X: 3, Y: 1}, geometry.Point{X: 0, Y: 3}, geometry.Point{X: 1, Y: 3})
+	// Flush against the wall on the right, moving along it.
+	box := AABB{Min: V(2, 0), Max: V(3, 1)}
+	if moved, hit := SweepAABB(box, V(0, 1), solid); hit.Collided || moved != box.Translate(V(0, 1)) {
+		t.Errorf("along wall: got %v, %+v", moved, hit)
+	}
+	// Flush against the wall, moving into it.
+	if moved, hit := SweepAABB(box, V(0.5, 0), solid); !hit.Collided || hit.Time > 1e-5 || moved != box {
+		t.Errorf("into wall: got %v, %+v", moved, hit)
+	}
+	// Flush against the wall, moving away from it.
+	if moved, hit := SweepAABB(box, V(-1, 0.5), solid); hit.Collided || moved != box.Translate(V(-1, 0.5)) {
+		t.Errorf("away from wall: got %v, %+v", moved, hit)
+	}
+	// Ending exactly against the floor.
+	box = AABB{Min: V(0, 1), Max: V(1, 2)}
+	if moved, hit := SweepAABB(box, V(0, 1), solid); hit.Collided || moved.Max.Y != 3 {
+		t.Errorf("onto floor: got %v, %+v", moved, hit)
+	}
+	// Stopped by the floor, the box touches it exactly.
+	moved, hit := SweepAABB(box, V(0.25, 1.7), solid)
+	if !hit.Collided || hit.Normal != V(0, -1) || moved.Max.Y != 3 {
+		t.Errorf("through floor: got %v, %+v", moved, hit)
+	}
+}
+
+func TestSweepAABBZeroAxis(t *testing.T) {
+	solid := walls(geometry.Point{X: 5, Y: 1}, geometry.Point{X: 1, Y: 5})
+	box := AABB{Min: V(0.5, 0.5), Max: V(1.5, 1.5)}
+	moved, hit := SweepAABB(box, V(10, 0), solid)
+	if !hit.Collided || hit.Cell != (geometry.Point{X: 5, Y: 1}) || hit.Normal != V(-1, 0) || moved.Max.X != 5 || moved.Min.Y != 0.5 {
+		t.Errorf("horizontal: got %v, %+v", moved, hit)
+	}
+	moved, hit = SweepAABB(box, V(0, 10), solid)
+	if !hit.Collided || hit.Cell != (geometry.Point{X: 1, Y: 5}) || hit.Normal != V(0, -1) || moved.Max.Y != 5 || moved.Min.X != 0.5 {
+		t.Errorf("vertical: got %v, %+v", moved, hit)
+	}
+	// The row of the box ends where the cell starts.
+	box = AABB{Min: V(0, 0), Max: V(1, 1)}
+	if _, hit = SweepAABB(box, V(10, 0), solid); hit.Collided {
+		t.Errorf("next row: got %+v", hit)
+	}
+	if moved, hit = SweepAABB(box, V(0, 0), solid); hit.Collided || moved != box {
+		t.Errorf("no movement: got %v, %+v", moved, hit)
+	}
+}
+
+// TestSweepAABBRandom compares SweepAABB with testing every solid cell.
+func TestSweepAABBRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var cells []geometry.Point
+		for j := 0; j < 30; j++ {
+			cells = append(cells, geometry.Point{X: rnd.Intn(12), Y: rnd.Intn(12)})
+		}
+		solid := walls(cells...)
+		// Half of the boxes and movements are on integer coordinates.
+		coord := func(n int) float64 {
+			if rnd.Intn(2) == 0 {
+				return float64(rnd.Intn(n))
+			}
+			return rnd.Float64() * float64(n)
+		}
+		size := V(0.2+coord(2), 0.2+coord(2))
+		box := AABB{Min: V(coord(12), coord(12))}
+		box.Max = box.Min.Add(size)
+		delta := V(coord(9)-4, coord(9)-4)
+		if rnd.Intn(4) == 0 {
+			delta.X = 0
+		} else if rnd.Intn(4) == 0 {
+			delta.Y = 0
+		}
+		want := Hit{Time: 1}
+		for _, c := range cells {
+			if time, normal, ok := sweepCell(box, delta, c); ok && time < want.Time {
+				want = Hit{Collided: true, Time: time, Normal: normal, Cell: c}
+			}
+		}
+		moved, hit := SweepAABB(box, delta, solid)
+		if hit.Collided != want.Collided || math.Abs(hit.Time-want.Time) > 1e-9 {
+			t.Fatalf("%v by %v: got %+v, want %+v", box, delta, hit, want)
+		}
+		for _, c := range cells {
+			if overlaps(moved, c) && !overlaps(box, c) {
+				t.Fatalf("%v by %v: moved into %v", box, delta, c)
+			}
+		}
+	}
+}
+
+// overlaps returns true if a box overlaps a cell by more than twice skin: a
+// box stopped by a cell can move by up to skin into others.
+func overlaps(b AABB, c geometry.Point) bool {
+	return b.Intersects(AABB{Min: CellCorner(c).Add(V(2*skin, 2*skin)), Max: CellCorner(c).Add(V(1-2*skin, 1-2*skin))})
+}
+
+func TestSlide(t *testing.T) {
+	// A floor along y = 3, and a wall along x = 4 above it.
+	solid := func(p geometry.Point) bool { return p.Y >= 3 || p.X >= 4 }
+	box := AABB{Min: V(0, 1), Max: V(1, 2)}
+	// Falling diagonally onto the floor, then sliding along it.
+	moved, hits := Slide(box, V(1, 2), solid)
+	if len(hits) != 1 || hits[0].Normal != V(0, -1) || moved.Max.Y != 3 || math.Abs(moved.Min.X-1) > 1e-9 {
+		t.Errorf("onto floor: got %v, %+v", moved, hits)
+	}
+	// Resting on the floor, sliding along it into the wall.
+	box = AABB{Min: V(0, 2), Max: V(1, 3)}
+	moved, hits = Slide(box, V(5, 0), solid)
+	if len(hits) != 1 || hits[0].Normal != V(-1, 0) || moved != (AABB{Min: V(3, 2), Max: V(4, 3)}) {
+		t.Errorf("along floor: got %v, %+v", moved, hits)
+	}
+	// Pushed into the corner, the box does not move.
+	moved, hits = Slide(moved, V(1, 1), solid)
+	if moved != (AABB{Min: V(3, 2), Max: V(4, 3)}) || len(hits) == 0 {
+		t.Errorf("into corner: got %v, %+v", moved, hits)
+	}
+	// Grazing the corner of a lone cell, the box is not deflected.
+	box = AABB{Min: V(0, 0), Max: V(1, 1)}
+	moved, hits = Slide(box, V(2, 2), walls(geometry.Point{X: 2, Y: 0}, geometry.Point{X: 0, Y: 2}))
+	if len(hits) != 0 || moved != box.Translate(V(2, 2)) {
+		t.Errorf("grazing corner: got %v, %+v", moved, hits)
+	}
+}
@@ -0,0 +1,252 @@
+package collision
+
+import (
+	"github.com/memmaker/go/geometry"
+	"math"
+)
+
+// AABB is an axis-aligned bounding box, from Min included to Max.
+type AABB struct {
+	Min Vec2
+	Max Vec2
+}
+
+// NewAABB returns a box of a given size centered on a position.
+func NewAABB(center, size Vec2) AABB {
+	half := size.Div(2)
+	return AABB{Min: center.Sub(half), Max: center.Add(half)}
+}
+
+// FromRect returns the box covering the cells of a range.
+func FromRect(rg geometry.Rect) AABB {
+	return AABB{Min: CellCorner(rg.Min), Max: CellCorner(rg.Max)}
+}
+
+// Center returns the center of the box.
+func (b AABB) Center() Vec2 {
+	return b.Min.Lerp(b.Max, 0.5)
+}
+
+// Size returns the size of the box.
+func (b AABB) Size() Vec2 {
+	return b.Max.Sub(b.Min)
+}
+
+// Translate returns the box moved by a vector.
+func (b AABB) Translate(v Vec2) AABB {
+	return AABB{Min: b.Min.Add(v), Max: b.Max.Add(v)}
+}
+
+// Cells returns the range of the grid cells the box overlaps. Cells which
+// the box only touches on a side are not included.
+func (b AABB) Cells() geometry.Rect {
+	return geometry.Rect{
+		Min: Cell(b.Min),
+		Max: geometry.Point{X: int(math.Ceil(b.Max.X)), Y: int(math.Ceil(b.Max.Y))},
+	}
+}
+
+// Contains returns true if a position is in the box.
+func (b AABB) Contains(v Vec2) bool {
+	return v.X >= b.Min.X && v.X < b.Max.X && v.Y >= b.Min.Y && v.Y < b.Max.Y
+}
+
+// Intersects returns true if the boxes overlap. Boxes which only touch on a
+// side do not overlap.
+func (b AABB) Intersects(o AABB) bool {
+	return b.Min.X < o.Max.X && o.Min.X < b.Max.X && b.Min.Y < o.Max.Y && o.Min.Y < b.Max.Y
+}
+
+// Penetration returns the shortest vector by which b has to be moved so that
+// it does not overlap o anymore, or the zero vector if the boxes do not
+// overlap.
+func (b AABB) Penetration(o AABB) Vec2 {
+	if !b.Intersects(o) {
+		return Vec2{}
+	}
+	left, right := o.Min.X-b.Max.X, o.Max.X-b.Min.X
+	up, down := o.Min.Y-b.Max.Y, o.Max.Y-b.Min.Y
+	dx := left
+	if -left > right {
+		dx = right
+	}
+	dy := up
+	if -up > down {
+		dy = down
+	}
+	if math.Abs(dx) < math.Abs(dy) {
+		return Vec2{X: dx}
+	}
+	return Vec2{Y: dy}
+}
+
+// ClosestPoint returns the position of the box closest to v.
+func (b AABB) ClosestPoint(v Vec2) Vec2 {
+	return Vec2{X: math.Max(b.Min.X, math.Min(v.X, b.Max.X)), Y: math.Max(b.Min.Y, math.Min(v.Y, b.Max.Y))}
+}
+
+// Circle is a disc of a given radius.
+type Circle struct {
+	Center Vec2
+	Radius float64
+}
+
+// Contains returns true if a position is in the circle.
+func (c Circle) Contains(v Vec2) bool {
+	return v.Sub(c.Center).LenSquared() <= c.Radius*c.Radius
+}
+
+// Intersects returns true if the circles overlap.
+func (c Circle) Intersects(o Circle) bool {
+	r := c.Radius + o.Radius
+	return o.Center.Sub(c.Center).LenSquared() < r*r
+}
+
+// IntersectsAABB returns true if the circle and the box overlap.
+func (c Circle) IntersectsAABB(b AABB) bool {
+	return b.ClosestPoint(c.Center).Sub(c.Center).LenSquared() < c.Radius*c.Radius
+}
+
+// Penetration returns the shortest vector by which c has to be moved so that
+// it does not overlap o anymore, or the zero vector if the circles do not
+// overlap.
+func (c Circle) Penetration(o Circle) Vec2 {
+	if !c.Intersects(o) {
+		return Vec2{}
+	}
+	d := c.Center.Sub(o.Center)
+	l := d.Len()
+	if l == 0 {
+		return Vec2{X: c.Radius + o.Radius}
+	}
+	return d.Mul((c.Radius + o.Radius - l) / l)
+}
+
+// Segment is the line segment from A to B.
+type Segment struct {
+	A Vec2
+	B Vec2
+}
+
+// Delta returns the vector from A to B.
+func (s Segment) Delta() Vec2 {
+	return s.B.Sub(s.A)
+}
+
+// Len returns the length of the segment.
+func (s Segment) Len() float64 {
+	return s.Delta().Len()
+}
+
+// At returns the position of the segment at t, from A for t = 0 to B for
+// t = 1.
+func (s Segment) At(t float64) Vec2 {
+	return s.A.Lerp(s.B, t)
+}
+
+// ClosestPoint returns the position of the segment closest to v.
+func (s Segment) ClosestPoint(v Vec2) Vec2 {
+	d := s.Delta()
+	l := d.LenSquared()
+	if l == 0 {
+		return s.A
+	}
+	t := math.Max(0, math.Min(1, v.Sub(s.A).Dot(d)/l))
+	return s.At(t)
+}
+
+// Intersects returns the intersection of two segments, if any. The returned
+// t is the position of the intersection on s, as with At. Collinear
+// overlapping segments intersect at the first common position of s.
+func (s Segment) Intersects(o Segment) (t float64, ok bool) {
+	r, q := s.Delta(), o.Delta()
+	denom := r.Cross(q)
+	ao := o.A.Sub(s.A)
+	if denom == 0 {
+		if ao.Cross(r) != 0 {
+			return 0, false // parallel
+		}
+		l := r.LenSquared()
+		if l == 0 {
+			return 0, o.ClosestPoint(s.A) == s.A
+		}
+		t0, t1 := ao.Dot(r)/l, o.B.Sub(s.A).Dot(r)/l
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t1 < 0 || t0 > 1 {
+			return 0, false
+		}
+		return math.Max(0, t0), true
+	}
+	t = ao.Cross(q) / denom
+	u := ao.Cross(r) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// IntersectsCircle returns the position of the first intersection of the
+// segment with a circle, if any, as with At. A segment starting in the
+// circle intersects it at t = 0.
+func (s Segment) IntersectsCircle(c Circle) (t float64, ok bool) {
+	d := s.Delta()
+	f := s.A.Sub(c.Center)
+	cc := f.LenSquared() - c.Radius*c.Radius
+	if cc <= 0 {
+		return 0, true
+	}
+	a := d.LenSquared()
+	b := f.Dot(d)
+	disc := b*b - a*cc
+	if a == 0 || disc < 0 {
+		return 0, false
+	}
+	t = (-b - math.Sqrt(disc)) / a
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// IntersectsAABB returns the position of the first intersection of the
+// segment with a box, if any, as with At, and the normal of the side of the
+// box that was hit. A segment starting in the box intersects it at t = 0,
+// with a zero normal.
+func (s Segment) IntersectsAABB(b AABB) (t float64, normal Vec2, ok bool) {
+	d := s.Delta()
+	tmin, tmax := 0.0, 1.0
+	for axis := 0; axis < 2; axis++ {
+		origin, delta, lo, hi := s.A.X, d.X, b.Min.X, b.Max.X
+		if axis == 1 {
+			origin, delta, lo, hi = s.A.Y, d.Y, b.Min.Y, b.Max.Y
+		}
+		if delta == 0 {
+			if origin < lo || origin >= hi {
+				return 0, Vec2{}, false
+			}
+			continue
+		}
+		t0, t1 := (lo-origin)/delta, (hi-origin)/delta
+		side := -1.0
+		if t0 > t1 {
+			t0, t1 = t1, t0
+			side = 1
+		}
+		if t0 > tmin {
+			tmin = t0
+			normal = Vec2{}
+			if axis == 0 {
+				normal.X = side
+			} else {
+				normal.Y = side
+			}
+		}
+		tmax = math.Min(tmax, t1)
+		if tmin > tmax {
+			return 0, Vec2{}, false
+		}
+	}
+	return tmin, normal, true
+}
@@ -0,0 +1,160 @@
+// Package collision implements continuous-space movement on top of the tile
+// grid: a float vector type, AABB, circle and segment shapes with
+// intersection tests, and swept AABBs against solid grid cells.
+//
+// Cell (x, y) of the grid covers the square from (x, y) to (x+1, y+1), so
+// that its center is at (x+0.5, y+0.5), as with fxtools.Raycast2D.
+package collision
+
+import (
+	"fmt"
+	"github.com/memmaker/go/geometry"
+	"math"
+)
+
+// Vec2 is a vector or position in continuous space.
+type Vec2 struct {
+	X float64
+	Y float64
+}
+
+// V returns the vector (x, y).
+func V(x, y float64) Vec2 {
+	return Vec2{X: x, Y: y}
+}
+
+// FromPointF returns the vector of a PointF.
+func FromPointF(f geometry.PointF) Vec2 {
+	return Vec2{X: f.X, Y: f.Y}
+}
+
+func (v Vec2) String() string {
+	return fmt.Sprintf("(%g,%g)", v.X, v.Y)
+}
+
+// PointF returns the PointF of a vector.
+func (v Vec2) PointF() geometry.PointF {
+	return geometry.PointF{X: v.X, Y: v.Y}
+}
+
+// Add returns vector v+w.
+func (v Vec2) Add(w Vec2) Vec2 {
+	return Vec2{X: v.X + w.X, Y: v.Y + w.Y}
+}
+
+// Sub returns vector v-w.
+func (v Vec2) Sub(w Vec2) Vec2 {
+	return Vec2{X: v.X - w.X, Y: v.Y - w.Y}
+}
+
+// Mul returns vector v*k.
+func (v Vec2) Mul(k float64) Vec2 {
+	return Vec2{X: v.X * k, Y: v.Y * k}
+}
+
+// Div returns vector v/k.
+func (v Vec2) Div(k float64) Vec2 {
+	return Vec2{X: v.X / k, Y: v.Y / k}
+}
+
+// Neg returns vector -v.
+func (v Vec2) Neg() Vec2 {
+	return Vec2{X: -v.X, Y: -v.Y}
+}
+
+// Dot returns the dot product of v and w.
+func (v Vec2) Dot(w Vec2) float64 {
+	return v.X*w.X + v.Y*w.Y
+}
+
+// Cross returns the z component of the cross product of v and w, which is
+// positive when w is clockwise from v on screen, as y grows downwards.
+func (v Vec2) Cross(w Vec2) float64 {
+	return v.X*w.Y - v.Y*w.X
+}
+
+// Len returns the length of v.
+func (v Vec2) Len() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// LenSquared returns the squared length of v.
+func (v Vec2) LenSquared() float64 {
+	return v.X*v.X + v.Y*v.Y
+}
+
+// Dist returns the distance between v and w.
+func (v Vec2) Dist(w Vec2) float64 {
+	return w.Sub(v).Len()
+}
+
+// Normalize returns a vector of length 1 in the direction of v, or the zero
+// vector if v is zero.
+func (v Vec2) Normalize() Vec2 {
+	l := v.Len()
+	if l == 0 {
+		return v
+	}
+	return v.Div(l)
+}
+
+// Lerp returns the linear interpolation from v to w: v for t = 0 and w for
+// t = 1.
+func (v Vec2) Lerp(w Vec2, t float64) Vec2 {
+	return Vec2{X: v.X + (w.X-v.X)*t, Y: v.Y + (w.Y-v.Y)*t}
+}
+
+// Perp returns v rotated by 90 degrees, clockwise on screen.
+func (v Vec2) Perp() Vec2 {
+	return Vec2{X: -v.Y, Y: v.X}
+}
+
+// Rotate returns v rotated by an angle in radians, clockwise on screen.
+func (v Vec2) Rotate(angle float64) Vec2 {
+	sin, cos := math.Sincos(angle)
+	return Vec2{X: v.X*cos - v.Y*sin, Y: v.X*sin + v.Y*cos}
+}
+
+// Angle returns the angle of v in radians, from the x axis.
+func (v Vec2) Angle() float64 {
+	return math.Atan2(v.Y, v.X)
+}
+
+// Project returns the projection of v on w.
+func (v Vec2) Project(w Vec2) Vec2 {
+	l := w.LenSquared()
+	if l == 0 {
+		return Vec2{}
+	}
+	return w.Mul(v.Dot(w) / l)
+}
+
+// Reflect returns v reflected off a surface of a given unit normal.
+func (v Vec2) Reflect(normal Vec2) Vec2 {
+	return v.Sub(normal.Mul(2 * v.Dot(normal)))
+}
+
+// Cell returns the grid cell containing a position.
+func Cell(v Vec2) geometry.Point {
+	return geometry.Point{X: int(math.Floor(v.X)), Y: int(math.Floor(v.Y))}
+}
+
+// CellCenter returns the center of a grid cell.
+func CellCenter(p geometry.Point) Vec2 {
+	return Vec2{X: float64(p.X) + 0.5, Y: float64(p.Y) + 0.5}
+}
+
+// CellCorner returns the top left corner of a grid cell.
+func CellCorner(p geometry.Point) Vec2 {
+	return Vec2{X: float64(p.X), Y: float64(p.Y)}
+}
+
+// SnapToCenter returns the center of the grid cell containing a position.
+func SnapToCenter(v Vec2) Vec2 {
+	return CellCenter(Cell(v))
+}
+
+// SnapToCorner returns the grid corner closest to a position.
+func SnapToCorner(v Vec2) Vec2 {
+	return Vec2{X: math.Round(v.X), Y: math.Round(v.Y)}
+}
@@ -0,0 +1,292 @@
+// This file implements windowed cooperative A* (WCA*), without the
+// hierarchical heuristic of WHCA*. For more information: Silver,
+// "Cooperative Pathfinding" (2005).
+
+package geometry
+
+// ReservationTable records which agent occupies which position at which
+// turn, so that agents planning one after another with CooperativeAstarPath
+// avoid each other.
+type ReservationTable struct {
+	cells map[spaceTime]int
+	moves map[spaceTimeMove]int
+}
+
+// spaceTime is a position at a given turn.
+type spaceTime struct {
+	P    Point
+	Turn int
+}
+
+// spaceTimeMove is a move from a position to another starting at a given
+// turn. Reserving moves prevents two agents from swapping places.
+type spaceTimeMove struct {
+	From, To Point
+	Turn     int
+}
+
+// NewReservationTable returns an empty reservation table.
+func NewReservationTable() *ReservationTable {
+	return &ReservationTable{
+		cells: make(map[spaceTime]int),
+		moves: make(map[spaceTimeMove]int),
+	}
+}
+
+// Reserve reserves a position at a turn for an agent.
+func (rt *ReservationTable) Reserve(p Point, turn int, agent int) {
+	rt.cells[spaceTime{P: p, Turn: turn}] = agent
+}
+
+// ReservePath reserves the positions of a path returned by
+// CooperativeAstarPath for an agent, with path[0] at the given turn, and the
+// moves between them. The last position is also reserved for the turns up
+// to holdUntil, inclusive, so that agents that reached their goal are not
+// run over.
+func (rt *ReservationTable) ReservePath(path []Point, turn int, agent int, holdUntil int) {
+	for i, p := range path {
+		rt.Reserve(p, turn+i, agent)
+		if i > 0 && path[i-1] != p {
+			rt.moves[spaceTimeMove{From: path[i-1], To: p, Turn: turn + i - 1}] = agent
+		}
+	}
+	if len(path) == 0 {
+		return
+	}
+	last := path[len(path)-1]
+	for t := turn + len(path); t <= holdUntil; t++ {
+		rt.Reserve(last, t, agent)
+	}
+}
+
+// ReservedBy returns the agent that reserved a position at a turn, or false
+// if it is free.
+func (rt *ReservationTable) ReservedBy(p Point, turn int) (int, bool) {
+	agent, ok := rt.cells[spaceTime{P: p, Turn: turn}]
+	return agent, ok
+}
+
+// IsFree reports whether an agent can occupy a position at a turn.
+func (rt *ReservationTable) IsFree(p Point, turn int, agent int) bool {
+	other, ok := rt.cells[spaceTime{P: p, Turn: turn}]
+	return !ok || other == agent
+}
+
+// canMove reports whether an agent can move from a position to another at a
+// turn without swapping places with another agent.
+func (rt *ReservationTable) canMove(from, to Point, turn int, agent int) bool {
+	if !rt.IsFree(to, turn+1, agent) {
+		return false
+	}
+	other, ok := rt.moves[spaceTimeMove{From: to, To: from, Turn: turn}]
+	return !ok || other == agent
+}
+
+// ClearAgent removes all reservations of an agent, for example before
+// replanning its path.
+func (rt *ReservationTable) ClearAgent(agent int) {
+	for key, other := range rt.cells {
+		if other == agent {
+			delete(rt.cells, key)
+		}
+	}
+	for key, other := range rt.moves {
+		if other == agent {
+			delete(rt.moves, key)
+		}
+	}
+}
+
+// ClearBefore removes all reservations for turns before the given one.
+func (rt *ReservationTable) ClearBefore(turn int) {
+	for key := range rt.cells {
+		if key.Turn < turn {
+			delete(rt.cells, key)
+		}
+	}
+	for key := range rt.moves {
+		if key.Turn < turn {
+			delete(rt.moves, key)
+		}
+	}
+}
+
+// Clear removes all reservations.
+func (rt *ReservationTable) Clear() {
+	rt.cells = make(map[spaceTime]int)
+	rt.moves = make(map[spaceTimeMove]int)
+}
+
+// Waiter can optionally be implemented by an Astar used with
+// CooperativeAstarPath to give a cost to waiting at a position. Without it,
+// waiting costs 1.
+type Waiter interface {
+	WaitCost(Point) int
+}
+
+// CooperativeAgent describes an agent for CooperativeAstarPaths.
+type CooperativeAgent struct {
+	ID       int
+	From, To Point
+}
+
+// CooperativeAstarPath returns a path over space and time for an agent, from
+// a position at a given turn towards another, that avoids the positions and
+// moves reserved by other agents. path[i] is the position of the agent at
+// turn+i, so the agent waits in place where a position repeats.
+//
+// The search looks at most window turns ahead. If the goal is further away,
+// the returned path ends at the most promising position after window turns,
+// and the agent should plan again before it runs out of path, typically
+// after window/2 turns. The path is not reserved, see
+// ReservationTable.ReservePath. It returns nil if no path was found.
+//
+// The estimation is used as in AstarPath, so the path is guided towards the
+// goal by the Astar's Estimation.
+func (pr *PathRange) CooperativeAstarPath(ast Astar, rt *ReservationTable, agent int, from, to Point, turn, window int) []Point {
+	if !from.In(pr.Rg) || !to.In(pr.Rg) || window < 1 {
+		return nil
+	}
+	waitCost := func(p Point) int {
+		if w, ok := ast.(Waiter); ok {
+			return w.WaitCost(p)
+		}
+		return 1
+	}
+	goalIsFree := func(t int) bool {
+		for ; t <= turn+window; t++ {
+			if !rt.IsFree(to, t, agent) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if pr.coop == nil {
+		pr.coop = newCoopNodes()
+	}
+	cn := pr.coop
+	cn.reset()
+	nqs := pr.AstarQueue[:0]
+	nq := &nqs
+	defer func() {
+		pr.AstarQueue = nqs[:0]
+	}()
+	pqInit(nq)
+	fromNode := cn.get(from, turn)
+	fromNode.Cost = 0
+	fromNode.Open = true
+	fromNode.Estimation = ast.Estimation(from, to)
+	fromNode.Rank = fromNode.Estimation
+	pqPush(nq, fromNode)
+	for nq.Len() > 0 {
+		n := pqPop(nq)
+		n.Open = false
+		n.Closed = true
+		t := cn.turns[n]
+
+		if n.P == to && goalIsFree(t) || t == turn+window {
+			path := make([]Point, t-turn+1)
+			for i := len(path) - 1; i >= 0; i-- {
+				path[i] = n.P
+				if i > 0 {
+					n = cn.get(n.Parent, turn+i-1)
+				}
+			}
+			return path
+		}
+
+		relax := func(q Point, stepCost int) {
+			if !q.In(pr.Rg) || !rt.canMove(n.P, q, t, agent) {
+				return
+			}
+			nbNode := cn.get(q, t+1)
+			if nbNode.Closed {
+				return
+			}
+			cost := n.Cost + stepCost
+			if cost >= nbNode.Cost {
+				return
+			}
+			if nbNode.Open {
+				pqRemove(nq, nbNode.Idx)
+			}
+			nbNode.Cost = cost
+			nbNode.Open = true
+			nbNode.Estimation = ast.Estimation(q, to)
+			nbNode.Rank = cost + nbNode.Estimation
+			nbNode.Parent = n.P
+			pqPush(nq, nbNode)
+		}
+		for _, q := range ast.Neighbors(n.P) {
+			relax(q, ast.Cost(n.P, q))
+		}
+		relax(n.P, waitCost(n.P))
+	}
+	return nil
+}
+
+// CooperativeAstarPaths plans the paths of several agents in order, each one
+// avoiding the paths of the previous ones, and reserves them in the table.
+// The agents that reach their goal keep it reserved until the end of the
+// window. The paths are returned in the order of the agents, with nil for
+// agents that could not find a path; those agents keep their position
+// reserved for the next turn.
+func (pr *PathRange) CooperativeAstarPaths(ast Astar, rt *ReservationTable, agents []CooperativeAgent, turn, window int) [][]Point {
+	paths := make([][]Point, len(agents))
+	for i, a := range agents {
+		path := pr.CooperativeAstarPath(ast, rt, a.ID, a.From, a.To, turn, window)
+		paths[i] = path
+		if path == nil {
+			rt.Reserve(a.From, turn+1, a.ID)
+			continue
+		}
+		rt.ReservePath(path, turn, a.ID, turn+window)
+	}
+	return paths
+}
+
+// coopChunk is the number of nodes allocated at once by coopNodes.
+const coopChunk = 1024
+
+// coopNodes holds the nodes of a CooperativeAstarPath search, one per
+// position and turn reached. The nodes are allocated in chunks that are
+// reused by the next searches, so memory only grows with the number of
+// nodes a search explores.
+type coopNodes struct {
+	nodes  map[spaceTime]*node
+	turns  map[*node]int
+	chunks [][]node
+	used   int // number of nodes used in the chunks
+}
+
+func newCoopNodes() *coopNodes {
+	return &coopNodes{
+		nodes: make(map[spaceTime]*node),
+		turns: make(map[*node]int),
+	}
+}
+
+// reset forgets the nodes of the previous search.
+func (cn *coopNodes) reset() {
+	clear(cn.nodes)
+	clear(cn.turns)
+	cn.used = 0
+}
+
+// get returns the node of a position at a turn, creating it if needed.
+func (cn *coopNodes) get(p Point, t int) *node {
+	key := spaceTime{P: p, Turn: t}
+	if n, ok := cn.nodes[key]; ok {
+		return n
+	}
+	if cn.used == len(cn.chunks)*coopChunk {
+		cn.chunks = append(cn.chunks, make([]node, coopChunk))
+	}
+	n := &cn.chunks[cn.used/coopChunk][cn.used%coopChunk]
+	cn.used++
+	*n = node{P: p, Cost: dstarInfinity}
+	cn.nodes[key] = n
+	cn.turns[n] = t
+	return n
+}
@@ -0,0 +1,213 @@
+package geometry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOutOfRange is reported when a path end is out of the PathRange's
+	// range.
+	ErrOutOfRange = errors.New("position out of range")
+	// ErrDisconnected is reported when the path ends are in different
+	// connected components.
+	ErrDisconnected = errors.New("positions in different connected components")
+	// ErrNoPath is reported when the path ends are in the same connected
+	// component but no path was found anyway, for example because of one
+	// way moves.
+	ErrNoPath = errors.New("no path found")
+)
+
+// PathError explains why no path was found between two positions.
+type PathError struct {
+	From, To Point
+	Err      error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("path from %v to %v: %v", e.From, e.To, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// PathDiagnostics describes a path search, for debugging AI and tuning
+// costs and estimations.
+type PathDiagnostics struct {
+	From, To Point
+	Path     []Point       // found path, nil if none
+	Cost     int           // total cost of the path, -1 if none
+	Expanded int           // number of positions expanded by the search
+	Visited  int           // number of positions reached, expanded or not
+	Explored []Point       // expanded positions, row by row
+	Duration time.Duration // duration of the search itself
+	Err      error         // a *PathError if no path was found
+}
+
+func (d *PathDiagnostics) String() string {
+	if d.Err != nil {
+		return fmt.Sprintf("%v (expanded %d, visited %d, in %v)", d.Err, d.Expanded, d.Visited, d.Duration)
+	}
+	return fmt.Sprintf("path from %v to %v: length %d, cost %d (expanded %d, visited %d, in %v)",
+		d.From, d.To, len(d.Path), d.Cost, d.Expanded, d.Visited, d.Duration)
+}
+
+// AstarPathDiagnostics is like AstarPath, but returns diagnostics about the
+// search. If no path is found, CCMap is used to explain why, so the
+// connected components cached by the PathRange are replaced.
+func (pr *PathRange) AstarPathDiagnostics(ast Astar, from, to Point) *PathDiagnostics {
+	d := &PathDiagnostics{From: from, To: to, Cost: -1}
+	idx := pr.astarIdx()
+	start := time.Now()
+	d.Path = pr.AstarPath(ast, from, to)
+	d.Duration = time.Since(start)
+	pr.collectExplored(d, idx)
+	if d.Path != nil {
+		d.Cost = 0
+		for i := 1; i < len(d.Path); i++ {
+			d.Cost += ast.Cost(d.Path[i-1], d.Path[i])
+		}
+		return d
+	}
+	d.Err = pr.explainNoPath(ast, from, to)
+	return d
+}
+
+// JPSPathDiagnostics is like JPSPath, but returns diagnostics about the
+// search, as AstarPathDiagnostics. The cost is the number of moves. Only
+// jump points are expanded, so that the explored positions are sparse.
+func (pr *PathRange) JPSPathDiagnostics(from, to Point, passable func(Point) bool, diags bool) *PathDiagnostics {
+	d := &PathDiagnostics{From: from, To: to, Cost: -1}
+	idx := pr.astarIdx()
+	start := time.Now()
+	d.Path = pr.JPSPath(nil, from, to, passable, diags)
+	d.Duration = time.Since(start)
+	pr.collectExplored(d, idx)
+	if d.Path != nil {
+		d.Cost = len(d.Path) - 1
+		return d
+	}
+	nb := &Neighbors{}
+	keep := func(p Point) bool {
+		return p.In(pr.Rg) && passable(p)
+	}
+	pather := pathFunc(func(p Point) []Point {
+		if !keep(p) {
+			return nil
+		}
+		if diags {
+			return nb.All(p, keep)
+		}
+		return nb.Cardinal(p, keep)
+	})
+	d.Err = pr.explainNoPath(pather, from, to)
+	return d
+}
+
+type pathFunc func(p Point) []Point
+
+func (f pathFunc) Neighbors(p Point) []Point {
+	return f(p)
+}
+
+// astarIdx returns the index of the last A* or JPS search, or -1 if none
+// ran yet.
+func (pr *PathRange) astarIdx() int {
+	if pr.AstarNodes == nil {
+		return -1
+	}
+	return pr.AstarNodes.Idx
+}
+
+// collectExplored gathers the nodes of the last A* or JPS search, if a
+// search ran since the given astarIdx: searches returning early, for example
+// when both path ends are the same, leave the nodes of a previous search.
+func (pr *PathRange) collectExplored(d *PathDiagnostics, idx int) {
+	nm := pr.AstarNodes
+	if nm == nil || nm.Idx == idx {
+		return
+	}
+	pr.Rg.Iter(func(p Point) {
+		n := nm.at(pr, p)
+		if n == nil {
+			return
+		}
+		d.Visited++
+		if n.Closed {
+			d.Expanded++
+			d.Explored = append(d.Explored, p)
+		}
+	})
+}
+
+func (pr *PathRange) explainNoPath(nb Pather, from, to Point) error {
+	err := &PathError{From: from, To: to, Err: ErrNoPath}
+	if !from.In(pr.Rg) || !to.In(pr.Rg) {
+		err.Err = ErrOutOfRange
+		return err
+	}
+	pr.CCMap(nb, from)
+	if pr.CCMapAt(from) != pr.CCMapAt(to) {
+		err.Err = ErrDisconnected
+	}
+	return err
+}
+
+// PathMark is the role of a position in PathDiagnostics.
+type PathMark int
+
+const (
+	MarkExplored PathMark = iota // expanded by the search
+	MarkPath                     // on the found path
+	MarkStart                    // start of the search
+	MarkGoal                     // goal of the search
+)
+
+// Marks calls a function for every position of interest, with its role:
+// first the explored positions, then the path positions, then the start and
+// the goal, so that later marks can be drawn over earlier ones.
+func (d *PathDiagnostics) Marks(fn func(p Point, mark PathMark)) {
+	for _, p := range d.Explored {
+		fn(p, MarkExplored)
+	}
+	for _, p := range d.Path {
+		fn(p, MarkPath)
+	}
+	fn(d.From, MarkStart)
+	fn(d.To, MarkGoal)
+}
+
+// ASCII renders the diagnostics on a range, one line per row: 'S' is the
+// start, 'G' the goal, '*' the path, '+' the other explored positions, '#'
+// the impassable positions and '.' the others. A nil passable function
+// marks no position as impassable.
+func (d *PathDiagnostics) ASCII(rg Rect, passable func(Point) bool) string {
+	size := rg.Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return ""
+	}
+	grid := make([]byte, size.X*size.Y)
+	rg.Iter(func(p Point) {
+		q := p.Sub(rg.Min)
+		grid[q.Y*size.X+q.X] = '.'
+		if passable != nil && !passable(p) {
+			grid[q.Y*size.X+q.X] = '#'
+		}
+	})
+	runes := [...]byte{MarkExplored: '+', MarkPath: '*', MarkStart: 'S', MarkGoal: 'G'}
+	d.Marks(func(p Point, mark PathMark) {
+		if p.In(rg) {
+			q := p.Sub(rg.Min)
+			grid[q.Y*size.X+q.X] = runes[mark]
+		}
+	})
+	var sb strings.Builder
+	for y := 0; y < size.Y; y++ {
+		sb.Write(grid[y*size.X : (y+1)*size.X])
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
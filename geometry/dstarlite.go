@@ -0,0 +1,290 @@
+// This file implements the D* Lite incremental path planning algorithm. For
+// more information: Koenig, Likhachev, "D* Lite" (2002).
+
+package geometry
+
+// dstarInfinity is larger than any path cost, while still allowing to add
+// costs to it without overflow.
+const dstarInfinity = int(^uint(0) >> 2)
+
+// DStarLite is a path planner for an agent moving towards a fixed goal in a
+// changing map. It keeps its search state between calls, so that after
+// the map changed around a few positions, or the agent moved, the path can be
+// repaired at a fraction of the cost of a new AstarPath search.
+//
+// The planner searches backwards from the goal and uses the Astar's
+// neighbors both as successors and predecessors, so paths should be
+// bidirectional, as for CCMapAll.
+type DStarLite struct {
+	ast   Astar
+	rg    Rect
+	w     int
+	start Point
+	last  Point // start position at the time of the last km update
+	goal  Point
+	km    int
+	nodes []node // Cost is g, Rank and Estimation are the two keys
+	rhs   []int
+	queue priorityQueue
+	// changing holds the neighbors of positions passed to Changing, which
+	// Update refreshes as well
+	changing []Point
+	// neighbors holds a copy of the neighbors of the expanded position, as
+	// Astar implementations may return a cached slice, which updateVertex
+	// would overwrite
+	neighbors []Point
+}
+
+// DStarLite returns a new D* Lite planner for the PathRange's range, for an
+// agent at from moving towards to. The first call to Path or Next computes
+// the initial path.
+func (pr *PathRange) DStarLite(ast Astar, from, to Point) *DStarLite {
+	d := &DStarLite{
+		ast: ast,
+		rg:  pr.Rg,
+		w:   pr.Rg.Size().X,
+	}
+	d.SetGoal(from, to)
+	return d
+}
+
+// SetGoal resets the planner for a new start and goal. This discards the
+// search state.
+func (d *DStarLite) SetGoal(from, to Point) {
+	size := d.rg.Size()
+	if d.nodes == nil {
+		d.nodes = make([]node, size.X*size.Y)
+		d.rhs = make([]int, size.X*size.Y)
+		d.queue = make(priorityQueue, 0, size.X+size.Y)
+	}
+	for i := range d.nodes {
+		d.nodes[i] = node{P: idxToPos(i, d.w).Add(d.rg.Min), Cost: dstarInfinity, Idx: -1}
+		d.rhs[i] = dstarInfinity
+	}
+	d.queue = d.queue[:0]
+	d.start, d.last, d.goal = from, from, to
+	d.km = 0
+	if !to.In(d.rg) {
+		return
+	}
+	d.rhs[d.idx(to)] = 0
+	d.push(to)
+}
+
+// Start returns the current position of the agent.
+func (d *DStarLite) Start() Point {
+	return d.start
+}
+
+// Goal returns the goal position.
+func (d *DStarLite) Goal() Point {
+	return d.goal
+}
+
+// MoveTo informs the planner that the agent moved to a new position.
+func (d *DStarLite) MoveTo(p Point) {
+	if p == d.start {
+		return
+	}
+	d.km += d.ast.Estimation(d.last, p)
+	d.last = p
+	d.start = p
+}
+
+// Changing informs the planner that the given positions are about to
+// change, before the map is modified. It is only needed for Astars whose
+// neighbors are not limited to the 8 adjacent positions, such as
+// WrapNeighbors or LayeredPather portals: the current neighbors of the
+// positions are then refreshed by the next Update as well, even if the
+// change removes the moves to them.
+func (d *DStarLite) Changing(ps ...Point) {
+	for _, p := range ps {
+		if p.In(d.rg) {
+			d.changing = append(d.changing, d.ast.Neighbors(p)...)
+		}
+	}
+}
+
+// Update informs the planner that the cost of moving into or out of the
+// given positions changed, for example because a door was closed or a wall
+// was dug. The positions, their 8 adjacent positions, their neighbors
+// returned by the Astar after the change, and those collected by Changing
+// before it, are refreshed. The repair work is done lazily on the next call
+// to Path or Next.
+func (d *DStarLite) Update(ps ...Point) {
+	var neighbors []Point
+	for _, p := range ps {
+		if !p.In(d.rg) {
+			continue
+		}
+		neighbors = append(neighbors, p)
+		for _, dir := range []Point{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}} {
+			neighbors = append(neighbors, p.Add(dir))
+		}
+		neighbors = append(neighbors, d.ast.Neighbors(p)...)
+	}
+	neighbors = append(neighbors, d.changing...)
+	d.changing = d.changing[:0]
+	for _, q := range neighbors {
+		if q.In(d.rg) {
+			d.updateVertex(q)
+		}
+	}
+}
+
+// Path returns the current best path from the agent's position to the goal,
+// including those positions, in the path order. It returns nil if the goal
+// is unreachable.
+func (d *DStarLite) Path() []Point {
+	if !d.start.In(d.rg) || !d.goal.In(d.rg) {
+		return nil
+	}
+	d.computeShortestPath()
+	if d.g(d.start) >= dstarInfinity {
+		return nil
+	}
+	path := []Point{d.start}
+	for p := d.start; p != d.goal; {
+		next, ok := d.bestSuccessor(p)
+		if !ok || len(path) > len(d.nodes) {
+			return nil
+		}
+		path = append(path, next)
+		p = next
+	}
+	return path
+}
+
+// Next returns the next position on the current best path, or false if the
+// goal is unreachable or already reached.
+func (d *DStarLite) Next() (Point, bool) {
+	if !d.start.In(d.rg) || !d.goal.In(d.rg) || d.start == d.goal {
+		return Point{}, false
+	}
+	d.computeShortestPath()
+	if d.g(d.start) >= dstarInfinity {
+		return Point{}, false
+	}
+	return d.bestSuccessor(d.start)
+}
+
+// Cost returns the cost of the current best path from the agent's position
+// to the goal, computed during the last call to Path or Next.
+func (d *DStarLite) Cost() int {
+	if !d.start.In(d.rg) {
+		return dstarInfinity
+	}
+	return d.g(d.start)
+}
+
+func (d *DStarLite) idx(p Point) int {
+	p = p.Sub(d.rg.Min)
+	return p.Y*d.w + p.X
+}
+
+func (d *DStarLite) g(p Point) int {
+	return d.nodes[d.idx(p)].Cost
+}
+
+func (d *DStarLite) bestSuccessor(p Point) (Point, bool) {
+	best, bestCost := Point{}, dstarInfinity
+	for _, q := range d.ast.Neighbors(p) {
+		if !q.In(d.rg) {
+			continue
+		}
+		cost := d.ast.Cost(p, q) + d.g(q)
+		if cost < bestCost {
+			best, bestCost = q, cost
+		}
+	}
+	return best, bestCost < dstarInfinity
+}
+
+func (d *DStarLite) key(p Point) (int, int) {
+	i := d.idx(p)
+	m := d.nodes[i].Cost
+	if d.rhs[i] < m {
+		m = d.rhs[i]
+	}
+	if m >= dstarInfinity {
+		return dstarInfinity, dstarInfinity
+	}
+	return m + d.ast.Estimation(d.start, p) + d.km, m
+}
+
+func (d *DStarLite) push(p Point) {
+	n := &d.nodes[d.idx(p)]
+	n.Rank, n.Estimation = d.key(p)
+	n.Open = true
+	pqPush(&d.queue, n)
+}
+
+func (d *DStarLite) remove(p Point) {
+	n := &d.nodes[d.idx(p)]
+	if n.Open {
+		pqRemove(&d.queue, n.Idx)
+		n.Open = false
+	}
+}
+
+func (d *DStarLite) updateVertex(p Point) {
+	i := d.idx(p)
+	if p != d.goal {
+		rhs := dstarInfinity
+		for _, q := range d.ast.Neighbors(p) {
+			if !q.In(d.rg) {
+				continue
+			}
+			if cost := d.ast.Cost(p, q) + d.g(q); cost < rhs {
+				rhs = cost
+			}
+		}
+		d.rhs[i] = rhs
+	}
+	d.remove(p)
+	if d.nodes[i].Cost != d.rhs[i] {
+		d.push(p)
+	}
+}
+
+func keyLess(k1, k2, l1, l2 int) bool {
+	return k1 < l1 || k1 == l1 && k2 < l2
+}
+
+func (d *DStarLite) computeShortestPath() {
+	si := d.idx(d.start)
+	for d.queue.Len() > 0 {
+		top := d.queue[0]
+		s1, s2 := d.key(d.start)
+		if !keyLess(top.Rank, top.Estimation, s1, s2) && d.rhs[si] == d.nodes[si].Cost {
+			return
+		}
+		k1, k2 := top.Rank, top.Estimation
+		n := pqPop(&d.queue)
+		n.Open = false
+		u := n.P
+		ui := d.idx(u)
+		if l1, l2 := d.key(u); keyLess(k1, k2, l1, l2) {
+			d.push(u)
+			continue
+		}
+		if n.Cost > d.rhs[ui] {
+			n.Cost = d.rhs[ui]
+			d.neighbors = append(d.neighbors[:0], d.ast.Neighbors(u)...)
+			for _, q := range d.neighbors {
+				if q.In(d.rg) {
+					d.updateVertex(q)
+				}
+			}
+			continue
+		}
+		n.Cost = dstarInfinity
+		d.updateVertex(u)
+		d.neighbors = append(d.neighbors[:0], d.ast.Neighbors(u)...)
+		for _, q := range d.neighbors {
+			if q.In(d.rg) {
+				d.updateVertex(q)
+			}
+		}
+	}
+}
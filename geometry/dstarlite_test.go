@@ -0,0 +1,50 @@
+package geometry
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestDStarLiteUpdates blocks and opens positions, and moves the agent along
+// its path, comparing the D* Lite path with AstarPath after each Update.
+func TestDStarLiteUpdates(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		m := newTestGridMap(random, 30, 20, 0.25)
+		m.diagonals = i%2 == 1
+		pr := NewPathRange(m.rg)
+		from, to := Point{X: 0, Y: 0}, Point{X: 29, Y: 19}
+		delete(m.walls, from)
+		delete(m.walls, to)
+		d := pr.DStarLite(m, from, to)
+		for step := 0; step < 40; step++ {
+			path := d.Path()
+			want := pr.AstarPath(m, d.Start(), to)
+			if got, wantCost := m.pathCost(path), m.pathCost(want); got != wantCost {
+				t.Fatalf("map %d, step %d: got path %v of cost %d, want cost %d", i, step, path, got, wantCost)
+			}
+			for k := 1; k < len(path); k++ {
+				if !m.passable(path[k]) {
+					t.Fatalf("map %d, step %d: path %v goes through a wall at %v", i, step, path, path[k])
+				}
+			}
+			if len(path) > 1 && random.Intn(3) == 0 {
+				d.MoveTo(path[1])
+			}
+			// Toggle a few positions, some of them on the path.
+			var changed []Point
+			for k := 0; k < 3; k++ {
+				p := Point{X: random.Intn(30), Y: random.Intn(20)}
+				if len(path) > 2 && k == 0 {
+					p = path[1+random.Intn(len(path)-2)]
+				}
+				if p == d.Start() || p == to {
+					continue
+				}
+				m.walls[p] = !m.walls[p]
+				changed = append(changed, p)
+			}
+			d.Update(changed...)
+		}
+	}
+}
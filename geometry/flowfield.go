@@ -0,0 +1,248 @@
+package geometry
+
+import (
+	"math"
+	"math/rand"
+)
+
+// FlowTieBreak selects among neighbors with the same cost in a FlowField.
+type FlowTieBreak int
+
+const (
+	// TieBreakFirst picks the first neighbor in the order returned by the
+	// Pather.
+	TieBreakFirst FlowTieBreak = iota
+	// TieBreakCardinal prefers straight moves over diagonal ones.
+	TieBreakCardinal
+	// TieBreakRandom picks a random neighbor, using FlowField.Random. This
+	// spreads out groups of agents following the same field.
+	TieBreakRandom
+)
+
+// FlowField maps every position of a range to the best next step towards
+// the sources of a dijkstra or breadth first map. Any number of agents can
+// share a field as long as they have the same goal, which makes it much
+// cheaper than searching one path per agent.
+//
+// A flee field, see Flee, leads away from the sources instead, taking into
+// account the layout of the map, so that agents don't run into dead ends.
+type FlowField struct {
+	Rg          Rect
+	Costs       []int // costs of the positions in Rg, row by row
+	Unreachable int   // cost of unreachable positions
+	TieBreak    FlowTieBreak
+	Random      *rand.Rand // used by TieBreakRandom, or the global source if nil
+	nb          Pather
+}
+
+// NewFlowField returns a flow field for the nodes returned by DijkstraMap or
+// BreadthFirstMap on a PathRange with the given range. Positions which are
+// not in nodes get the cost unreachable, which should be the maxCost + 1 used
+// for the map. The Pather is used to find the neighbors of a position when
+// looking up the next step.
+func NewFlowField(nb Pather, rg Rect, nodes []Node, unreachable int) *FlowField {
+	size := rg.Size()
+	f := &FlowField{
+		Rg:          rg,
+		Costs:       make([]int, size.X*size.Y),
+		Unreachable: unreachable,
+		nb:          nb,
+	}
+	for i := range f.Costs {
+		f.Costs[i] = unreachable
+	}
+	for _, n := range nodes {
+		if n.P.In(rg) {
+			f.Costs[f.idx(n.P)] = n.Cost
+		}
+	}
+	return f
+}
+
+// DijkstraFlowField computes a dijkstra map and returns the corresponding
+// flow field. The field does not share memory with the PathRange's caches.
+func (pr *PathRange) DijkstraFlowField(dij Dijkstra, sources []Point, maxCost int) *FlowField {
+	nodes := pr.DijkstraMap(dij, sources, maxCost)
+	return NewFlowField(dij, pr.Rg, nodes, maxCost+1)
+}
+
+// BreadthFirstFlowField computes a breadth first map and returns the
+// corresponding flow field. The field does not share memory with the
+// PathRange's caches.
+func (pr *PathRange) BreadthFirstFlowField(nb Pather, sources []Point, maxCost int) *FlowField {
+	nodes := pr.BreadthFirstMap(nb, sources, maxCost)
+	return NewFlowField(nb, pr.Rg, nodes, maxCost+1)
+}
+
+// FleeFlowField computes a dijkstra map and returns the corresponding flee
+// field. See FlowField.Flee. The maxCost should be large enough to cover the
+// whole map.
+func (pr *PathRange) FleeFlowField(dij Dijkstra, sources []Point, maxCost int, factor float64) *FlowField {
+	return pr.DijkstraFlowField(dij, sources, maxCost).Flee(dij, factor)
+}
+
+func (f *FlowField) idx(p Point) int {
+	p = p.Sub(f.Rg.Min)
+	return p.Y*f.Rg.Size().X + p.X
+}
+
+// CostAt returns the cost of a position. It returns Unreachable if the
+// position is out of range or unreachable.
+func (f *FlowField) CostAt(p Point) int {
+	if !p.In(f.Rg) {
+		return f.Unreachable
+	}
+	return f.Costs[f.idx(p)]
+}
+
+// Next returns the neighbor of a position with the lowest cost, if that cost
+// is lower than the cost of the position. It returns false at the sources, or
+// local minimums of a flee field, and for unreachable positions.
+func (f *FlowField) Next(p Point) (Point, bool) {
+	cost := f.CostAt(p)
+	if cost >= f.Unreachable {
+		return p, false
+	}
+	best, bestCost, ties := p, cost, 0
+	for _, q := range f.nb.Neighbors(p) {
+		qCost := f.CostAt(q)
+		if qCost >= f.Unreachable || qCost > bestCost {
+			continue
+		}
+		if qCost < bestCost {
+			best, bestCost, ties = q, qCost, 1
+			continue
+		}
+		if best == p {
+			// Same cost as the current position.
+			continue
+		}
+		ties++
+		if f.preferTie(p, best, q, ties) {
+			best = q
+		}
+	}
+	return best, best != p
+}
+
+// preferTie reports whether candidate should replace best, which have the
+// same cost. The candidate is the ties-th neighbor with that cost.
+func (f *FlowField) preferTie(p, best, candidate Point, ties int) bool {
+	switch f.TieBreak {
+	case TieBreakCardinal:
+		return isDiagonalStep(best.Sub(p)) && !isDiagonalStep(candidate.Sub(p))
+	case TieBreakRandom:
+		// Reservoir sampling, so that each tie is equally likely.
+		if f.Random != nil {
+			return f.Random.Intn(ties) == 0
+		}
+		return rand.Intn(ties) == 0
+	}
+	return false
+}
+
+func isDiagonalStep(delta Point) bool {
+	return delta.X != 0 && delta.Y != 0
+}
+
+// Direction returns the direction of the step returned by Next. It returns
+// false if there is no such step, or if it is not a step to an adjacent
+// position.
+func (f *FlowField) Direction(p Point) (CompassDirection, bool) {
+	next, ok := f.Next(p)
+	if !ok {
+		return East, false
+	}
+	delta := next.Sub(p)
+	if delta.X < -1 || delta.X > 1 || delta.Y < -1 || delta.Y > 1 {
+		return East, false
+	}
+	return CompassDirection(math.Round(DirectionVectorToAngleInDegrees(delta))), true
+}
+
+// Path follows the field from a position to a source, or a local minimum,
+// including those positions. It stops after maxLength steps.
+func (f *FlowField) Path(from Point, maxLength int) []Point {
+	path := []Point{from}
+	for p := from; len(path) <= maxLength; {
+		next, ok := f.Next(p)
+		if !ok {
+			break
+		}
+		path = append(path, next)
+		p = next
+	}
+	return path
+}
+
+// Flee returns a flee field, built with the technique described in "The
+// Incredible Power of Dijkstra Maps" by Brian Walker: the costs are
+// multiplied by -factor and the map is rescanned, so that the positions far
+// from the sources become the new sources. Agents following the flee field
+// move away from the sources but prefer escape routes over dead ends. A
+// factor around 1.2 makes agents accept passing closer to the sources if
+// that leads them to a better place; larger factors make them more cowardly.
+func (f *FlowField) Flee(dij Dijkstra, factor float64) *FlowField {
+	flee := &FlowField{
+		Rg:          f.Rg,
+		Costs:       make([]int, len(f.Costs)),
+		Unreachable: f.Unreachable,
+		TieBreak:    f.TieBreak,
+		Random:      f.Random,
+		nb:          dij,
+	}
+	for i, cost := range f.Costs {
+		if cost >= f.Unreachable {
+			flee.Costs[i] = f.Unreachable
+			continue
+		}
+		flee.Costs[i] = int(math.Round(float64(cost) * -factor))
+	}
+	flee.Rescan(dij)
+	return flee
+}
+
+// Rescan propagates the costs of the field, so that no position costs more
+// than the cheapest neighbor plus the cost of moving from there. It is used
+// after modifying the costs, for example to combine several fields, and is
+// needed to make a field with negative costs usable.
+func (f *FlowField) Rescan(dij Dijkstra) {
+	nodes := make([]node, len(f.Costs))
+	nq := make(priorityQueue, 0, len(f.Costs))
+	w := f.Rg.Size().X
+	for i, cost := range f.Costs {
+		if cost >= f.Unreachable {
+			continue
+		}
+		n := &nodes[i]
+		n.P = idxToPos(i, w).Add(f.Rg.Min)
+		n.Cost = cost
+		n.Rank = cost
+		n.Open = true
+		pqPush(&nq, n)
+	}
+	for nq.Len() > 0 {
+		n := pqPop(&nq)
+		n.Open = false
+		for _, q := range dij.Neighbors(n.P) {
+			if !q.In(f.Rg) {
+				continue
+			}
+			cost := n.Cost + dij.Cost(n.P, q)
+			qi := f.idx(q)
+			if cost >= f.Costs[qi] {
+				continue
+			}
+			f.Costs[qi] = cost
+			nbNode := &nodes[qi]
+			if nbNode.Open {
+				pqRemove(&nq, nbNode.Idx)
+			}
+			nbNode.P = q
+			nbNode.Cost = cost
+			nbNode.Rank = cost
+			nbNode.Open = true
+			pqPush(&nq, nbNode)
+		}
+	}
+}
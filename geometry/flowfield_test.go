@@ -0,0 +1,88 @@
+package geometry
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFlowFieldCosts(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		m := newTestGridMap(random, 30, 20, 0.3)
+		m.diagonals = i%2 == 1
+		pr := NewPathRange(m.rg)
+		source := Point{X: random.Intn(30), Y: random.Intn(20)}
+		delete(m.walls, source)
+		f := pr.DijkstraFlowField(m, []Point{source}, 10000)
+		m.rg.Iter(func(p Point) {
+			if !m.passable(p) {
+				return
+			}
+			want := m.pathCost(pr.AstarPath(m, p, source))
+			if got := f.CostAt(p); want < 0 && got != f.Unreachable || want >= 0 && got != want {
+				t.Fatalf("map %d: cost at %v: got %d, want %d", i, p, got, want)
+			}
+			if want < 0 {
+				if _, ok := f.Next(p); ok {
+					t.Fatalf("map %d: unreachable %v has a next step", i, p)
+				}
+				return
+			}
+			path := f.Path(p, 1000)
+			if path[len(path)-1] != source {
+				t.Fatalf("map %d: path from %v does not reach %v: %v", i, p, source, path)
+			}
+			for k := 1; k < len(path); k++ {
+				if !m.passable(path[k]) || DistanceChebyshev(path[k-1], path[k]) != 1 || f.CostAt(path[k]) >= f.CostAt(path[k-1]) {
+					t.Fatalf("map %d: invalid path from %v: %v", i, p, path)
+				}
+			}
+		})
+	}
+}
+
+func TestBreadthFirstFlowFieldPaths(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	m := newTestGridMap(random, 30, 20, 0.3)
+	pr := NewPathRange(m.rg)
+	sources := []Point{{X: 3, Y: 3}, {X: 25, Y: 15}}
+	for _, s := range sources {
+		delete(m.walls, s)
+	}
+	f := pr.BreadthFirstFlowField(m, sources, 10000)
+	f.TieBreak = TieBreakRandom
+	f.Random = random
+	m.rg.Iter(func(p Point) {
+		if f.CostAt(p) >= f.Unreachable {
+			return
+		}
+		// Every step gets one closer to the nearest source.
+		if path := f.Path(p, 1000); len(path)-1 != f.CostAt(p) || f.CostAt(path[len(path)-1]) != 0 {
+			t.Fatalf("path from %v of cost %d: %v", p, f.CostAt(p), path)
+		}
+	})
+}
+
+func TestFleeFlowField(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	m := newTestGridMap(random, 30, 20, 0.2)
+	m.diagonals = true
+	pr := NewPathRange(m.rg)
+	threat := Point{X: 15, Y: 10}
+	delete(m.walls, threat)
+	f := pr.FleeFlowField(m, []Point{threat}, 10000, 1.2)
+	for _, p := range append([]Point(nil), m.Neighbors(threat)...) {
+		path := f.Path(p, 1000)
+		if len(path) < 2 {
+			t.Fatalf("no flee path from %v", p)
+		}
+		for k := 1; k < len(path); k++ {
+			if path[k] == threat || f.CostAt(path[k]) >= f.CostAt(path[k-1]) {
+				t.Fatalf("invalid flee path from %v: %v", p, path)
+			}
+		}
+		if _, ok := f.Next(path[len(path)-1]); ok {
+			t.Fatalf("flee path from %v does not end at a local minimum: %v", p, path)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package geometry
+
+import "math"
+
+// This file implements hexagonal grids. For more information:
+// https://www.redblobgames.com/grids/hexagons/
+//
+// Hex maps are stored like square maps, with offset coordinates in a Point,
+// so that PathRange and Rect work unchanged: use a HexPather as the Pather,
+// Dijkstra or Astar.
+
+// Hex is a position on a hexagonal grid in axial coordinates. The third cube
+// coordinate is S = -Q-R.
+type Hex struct {
+	Q, R int
+}
+
+// HexDirections are the offsets of the six neighbors of a hex, starting East
+// and going counter-clockwise for pointy-top layouts.
+var HexDirections = [6]Hex{
+	{Q: 1, R: 0}, {Q: 1, R: -1}, {Q: 0, R: -1},
+	{Q: -1, R: 0}, {Q: -1, R: 1}, {Q: 0, R: 1},
+}
+
+// NewHexFromCube returns the hex with the given cube coordinates, which
+// must satisfy q+r+s == 0.
+func NewHexFromCube(q, r, s int) Hex {
+	return Hex{Q: q, R: r}
+}
+
+// Cube returns the cube coordinates of the hex.
+func (h Hex) Cube() (q, r, s int) {
+	return h.Q, h.R, -h.Q - h.R
+}
+
+func (h Hex) S() int {
+	return -h.Q - h.R
+}
+
+func (h Hex) Add(o Hex) Hex {
+	return Hex{Q: h.Q + o.Q, R: h.R + o.R}
+}
+
+func (h Hex) Sub(o Hex) Hex {
+	return Hex{Q: h.Q - o.Q, R: h.R - o.R}
+}
+
+func (h Hex) Scale(k int) Hex {
+	return Hex{Q: h.Q * k, R: h.R * k}
+}
+
+// Neighbor returns the adjacent hex in one of the six HexDirections.
+func (h Hex) Neighbor(direction int) Hex {
+	return h.Add(HexDirections[((direction%6)+6)%6])
+}
+
+// Neighbors returns the six adjacent hexes.
+func (h Hex) Neighbors() [6]Hex {
+	var neighbors [6]Hex
+	for i, d := range HexDirections {
+		neighbors[i] = h.Add(d)
+	}
+	return neighbors
+}
+
+// Distance returns the number of steps between two hexes.
+func (h Hex) Distance(o Hex) int {
+	d := h.Sub(o)
+	return (Abs(d.Q) + Abs(d.R) + Abs(d.S())) / 2
+}
+
+// RotateLeft rotates the hex by 60 degrees counter-clockwise around the
+// origin.
+func (h Hex) RotateLeft() Hex {
+	return Hex{Q: -h.S(), R: -h.Q}
+}
+
+// RotateRight rotates the hex by 60 degrees clockwise around the origin.
+func (h Hex) RotateRight() Hex {
+	return Hex{Q: -h.R, R: -h.S()}
+}
+
+// RotateAround rotates the hex around a center by steps of 60 degrees,
+// counter-clockwise for positive steps.
+func (h Hex) RotateAround(center Hex, steps int) Hex {
+	d := h.Sub(center)
+	steps = ((steps % 6) + 6) % 6
+	for i := 0; i < steps; i++ {
+		d = d.RotateLeft()
+	}
+	return center.Add(d)
+}
+
+// Ring returns the hexes at exactly radius steps from the hex, in order
+// around the ring.
+func (h Hex) Ring(radius int) []Hex {
+	if radius <= 0 {
+		return []Hex{h}
+	}
+	ring := make([]Hex, 0, 6*radius)
+	p := h.Add(HexDirections[4].Scale(radius))
+	for side := 0; side < 6; side++ {
+		for i := 0; i < radius; i++ {
+			ring = append(ring, p)
+			p = p.Neighbor(side)
+		}
+	}
+	return ring
+}
+
+// Spiral returns the hexes at most radius steps from the hex, ring by ring
+// starting with the hex itself.
+func (h Hex) Spiral(radius int) []Hex {
+	hexes := []Hex{h}
+	for r := 1; r <= radius; r++ {
+		hexes = append(hexes, h.Ring(r)...)
+	}
+	return hexes
+}
+
+// Line returns the hexes on a straight line between two hexes, including
+// both.
+func (h Hex) Line(to Hex) []Hex {
+	n := h.Distance(to)
+	line := make([]Hex, 0, n+1)
+	// The nudge avoids ambiguous roundings on hex edges.
+	aq, ar := float64(h.Q)+1e-6, float64(h.R)+1e-6
+	bq, br := float64(to.Q)+1e-6, float64(to.R)+1e-6
+	for i := 0; i <= n; i++ {
+		t := 0.0
+		if n > 0 {
+			t = float64(i) / float64(n)
+		}
+		line = append(line, HexRound(aq+(bq-aq)*t, ar+(br-ar)*t))
+	}
+	return line
+}
+
+// HexRound returns the hex containing fractional axial coordinates.
+func HexRound(q, r float64) Hex {
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+	return Hex{Q: int(rq), R: int(rr)}
+}
+
+// HexLayout is a way of storing hexes in the rows and columns of a square
+// grid, known as offset coordinates.
+type HexLayout int
+
+const (
+	// HexOddR has pointy-top hexes, with odd rows shoved right.
+	HexOddR HexLayout = iota
+	// HexEvenR has pointy-top hexes, with even rows shoved right.
+	HexEvenR
+	// HexOddQ has flat-top hexes, with odd columns shoved down.
+	HexOddQ
+	// HexEvenQ has flat-top hexes, with even columns shoved down.
+	HexEvenQ
+)
+
+// ToHex returns the hex stored at a grid position.
+func (l HexLayout) ToHex(p Point) Hex {
+	switch l {
+	case HexEvenR:
+		return Hex{Q: p.X - (p.Y+(p.Y&1))/2, R: p.Y}
+	case HexOddQ:
+		return Hex{Q: p.X, R: p.Y - (p.X-(p.X&1))/2}
+	case HexEvenQ:
+		return Hex{Q: p.X, R: p.Y - (p.X+(p.X&1))/2}
+	}
+	return Hex{Q: p.X - (p.Y-(p.Y&1))/2, R: p.Y}
+}
+
+// ToPoint returns the grid position of a hex.
+func (l HexLayout) ToPoint(h Hex) Point {
+	switch l {
+	case HexEvenR:
+		return Point{X: h.Q + (h.R+(h.R&1))/2, Y: h.R}
+	case HexOddQ:
+		return Point{X: h.Q, Y: h.R + (h.Q-(h.Q&1))/2}
+	case HexEvenQ:
+		return Point{X: h.Q, Y: h.R + (h.Q+(h.Q&1))/2}
+	}
+	return Point{X: h.Q + (h.R-(h.R&1))/2, Y: h.R}
+}
+
+// Distance returns the number of steps between two grid positions. It can
+// be used as the Astar estimation on hex maps.
+func (l HexLayout) Distance(p, q Point) int {
+	return l.ToHex(p).Distance(l.ToHex(q))
+}
+
+// Line returns the grid positions on a straight line between two grid
+// positions, including both.
+func (l HexLayout) Line(from, to Point) []Point {
+	hexes := l.ToHex(from).Line(l.ToHex(to))
+	return l.toPoints(hexes)
+}
+
+// Ring returns the grid positions at exactly radius steps from a grid
+// position.
+func (l HexLayout) Ring(center Point, radius int) []Point {
+	return l.toPoints(l.ToHex(center).Ring(radius))
+}
+
+// Spiral returns the grid positions at most radius steps from a grid
+// position.
+func (l HexLayout) Spiral(center Point, radius int) []Point {
+	return l.toPoints(l.ToHex(center).Spiral(radius))
+}
+
+func (l HexLayout) toPoints(hexes []Hex) []Point {
+	points := make([]Point, len(hexes))
+	for i, h := range hexes {
+		points[i] = l.ToPoint(h)
+	}
+	return points
+}
+
+// FOV returns the grid positions of a range visible from a center within a
+// radius. A position is visible if the straight hex line from the center
+// reaches it without passing through a position that is not transparent.
+// Opaque positions, such as walls, are visible themselves. Positions out of
+// the range are neither visible nor passed to transparent, and stop the
+// lines as opaque positions.
+func (l HexLayout) FOV(rg Rect, center Point, radius int, transparent func(Point) bool) []Point {
+	if !center.In(rg) {
+		return nil
+	}
+	origin := l.ToHex(center)
+	visible := map[Hex]bool{origin: true}
+	result := []Point{center}
+	for _, target := range origin.Ring(radius) {
+		for _, h := range origin.Line(target)[1:] {
+			p := l.ToPoint(h)
+			if !p.In(rg) {
+				break
+			}
+			if !visible[h] {
+				visible[h] = true
+				result = append(result, p)
+			}
+			if !transparent(p) {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// HexNeighbors fetches adjacent grid positions on a hex map. Like
+// Neighbors, its methods return a cached slice for efficiency, so results
+// are invalidated by next method calls. It is suitable for use in satisfying
+// the Dijkstra, Astar and Pather interfaces on hex maps.
+type HexNeighbors struct {
+	Layout HexLayout
+	ps     []Point
+}
+
+// All returns the 6 adjacent grid positions, filtered by keep function.
+func (nb *HexNeighbors) All(p Point, keep func(Point) bool) []Point {
+	nb.ps = nb.ps[:0]
+	h := nb.Layout.ToHex(p)
+	for _, d := range HexDirections {
+		q := nb.Layout.ToPoint(h.Add(d))
+		if keep(q) {
+			nb.ps = append(nb.ps, q)
+		}
+	}
+	return nb.ps
+}
+
+// HexPather implements the Pather, Dijkstra and Astar interfaces on a hex
+// map: it returns the passable adjacent positions within a range.
+//
+// HexPather elements must be created with NewHexPather.
+type HexPather struct {
+	Layout   HexLayout
+	Rg       Rect
+	Passable func(p Point) bool
+
+	// StepCost returns the cost of a move between adjacent positions. A nil
+	// StepCost costs 1 for all moves. Costs should be at least 1 for
+	// Estimation to be correct.
+	StepCost func(from, to Point) int
+
+	nb HexNeighbors
+}
+
+// NewHexPather returns a pather for a hex map covering a range. A nil
+// passable allows all positions.
+func NewHexPather(layout HexLayout, rg Rect, passable func(p Point) bool) *HexPather {
+	if passable == nil {
+		passable = func(Point) bool { return true }
+	}
+	return &HexPather{
+		Layout:   layout,
+		Rg:       rg,
+		Passable: passable,
+		nb:       HexNeighbors{Layout: layout},
+	}
+}
+
+// Neighbors implements Pather.Neighbors. The returned slice is cached, so it
+// is invalidated by the next call.
+func (hp *HexPather) Neighbors(p Point) []Point {
+	hp.nb.Layout = hp.Layout
+	return hp.nb.All(p, func(q Point) bool {
+		return q.In(hp.Rg) && hp.Passable(q)
+	})
+}
+
+// Cost implements Dijkstra.Cost. It returns the StepCost.
+func (hp *HexPather) Cost(from, to Point) int {
+	if hp.StepCost == nil {
+		return 1
+	}
+	return hp.StepCost(from, to)
+}
+
+// Estimation implements Astar.Estimation. It returns the number of steps
+// between the positions.
+func (hp *HexPather) Estimation(from, to Point) int {
+	return hp.Layout.Distance(from, to)
+}
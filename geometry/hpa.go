@@ -0,0 +1,405 @@
+// This file implements hierarchical path finding (HPA*). For more
+// information: Botea, Müller, Schaeffer, "Near Optimal Hierarchical
+// Path-Finding" (2004).
+
+package geometry
+
+// hpaMaxSingleEntrance is the length up to which a run of open border cells
+// gets a single entrance in its middle. Longer runs get one at each end.
+const hpaMaxSingleEntrance = 6
+
+// HPAStar is a hierarchical path finder for large maps. It partitions a range
+// into square clusters and caches an abstract graph of the entrances between
+// neighboring clusters, with the costs of the paths between the entrances of
+// each cluster. A path query only searches the abstract graph and the
+// clusters of the start and goal positions, so it is much faster than
+// AstarPath on large maps, at the price of slightly suboptimal paths.
+//
+// Entrances are found by checking which border positions are neighbors of
+// each other in both directions, straight across the border, so that
+// obstacles for which the Astar returns neighbors do not become entrances.
+// Paths should be bidirectional, as for CCMapAll.
+//
+// When the passability of a position changes, call Update, which only
+// rebuilds the cluster of that position and its neighbors.
+type HPAStar struct {
+	ast         Astar
+	rg          Rect
+	clusterSize int
+	columns     int // number of clusters per row
+	rows        int // number of clusters per column
+	clusters    []hpaCluster
+	borders     map[hpaBorder][]hpaEntrance
+	local       *PathRange // used for searches within a single cluster
+}
+
+// hpaCluster holds the abstract nodes of a cluster and their edges, both to
+// the other nodes of the cluster and to the nodes of neighboring clusters.
+type hpaCluster struct {
+	rg    Rect
+	nodes []Point
+	edges map[Point][]hpaEdge
+}
+
+// hpaEdge is an abstract edge. Its path excludes the origin and includes the
+// destination.
+type hpaEdge struct {
+	from Point
+	to   Point
+	cost int
+	path []Point
+}
+
+// hpaBorder identifies the border between two neighboring clusters, with
+// cluster A being left of or above cluster B.
+type hpaBorder struct {
+	A, B int
+}
+
+// hpaEntrance is a pair of neighboring positions on each side of a border.
+type hpaEntrance struct {
+	A, B Point
+}
+
+// HPAStar returns a hierarchical path finder for the PathRange's range, using
+// clusters of clusterSize x clusterSize positions. The abstract graph is
+// built immediately, which amounts to a few local A* searches per cluster.
+func (pr *PathRange) HPAStar(ast Astar, clusterSize int) *HPAStar {
+	if clusterSize < 2 {
+		clusterSize = 2
+	}
+	size := pr.Rg.Size()
+	h := &HPAStar{
+		ast:         ast,
+		rg:          pr.Rg,
+		clusterSize: clusterSize,
+		columns:     (size.X + clusterSize - 1) / clusterSize,
+		rows:        (size.Y + clusterSize - 1) / clusterSize,
+		borders:     make(map[hpaBorder][]hpaEntrance),
+		local:       NewPathRange(NewRect(0, 0, clusterSize, clusterSize)),
+	}
+	h.clusters = make([]hpaCluster, h.columns*h.rows)
+	for ci := range h.clusters {
+		cx, cy := ci%h.columns, ci/h.columns
+		min := h.rg.Min.Add(Point{X: cx * clusterSize, Y: cy * clusterSize})
+		h.clusters[ci].rg = NewRect(min.X, min.Y, min.X+clusterSize, min.Y+clusterSize).Intersect(h.rg)
+	}
+	for ci := range h.clusters {
+		h.updateBorders(ci)
+	}
+	for ci := range h.clusters {
+		h.buildCluster(ci)
+	}
+	return h
+}
+
+// Range returns the range covered by the path finder.
+func (h *HPAStar) Range() Rect {
+	return h.rg
+}
+
+// Update must be called after the passability of a position, or the cost of
+// moving into it, changed. It rebuilds the entrances and cached paths of the
+// cluster of the position and of its neighboring clusters.
+func (h *HPAStar) Update(p Point) {
+	if !p.In(h.rg) {
+		return
+	}
+	ci := h.clusterIndex(p)
+	h.updateBorders(ci)
+	h.buildCluster(ci)
+	for _, cj := range h.neighborClusters(ci) {
+		h.buildCluster(cj)
+	}
+}
+
+// UpdateRect is like Update for every position of a range, rebuilding each
+// affected cluster only once.
+func (h *HPAStar) UpdateRect(rg Rect) {
+	rg = rg.Intersect(h.rg)
+	if rg.Empty() {
+		return
+	}
+	min, max := h.clusterCoords(rg.Min), h.clusterCoords(rg.Max.Sub(Point{X: 1, Y: 1}))
+	affected := map[int]bool{}
+	for cy := min.Y; cy <= max.Y; cy++ {
+		for cx := min.X; cx <= max.X; cx++ {
+			ci := cy*h.columns + cx
+			h.updateBorders(ci)
+			affected[ci] = true
+			for _, cj := range h.neighborClusters(ci) {
+				affected[cj] = true
+			}
+		}
+	}
+	for ci := range affected {
+		h.buildCluster(ci)
+	}
+}
+
+// Path returns a path from a position to another, including those positions,
+// in the path order. It returns nil if no path was found.
+func (h *HPAStar) Path(from, to Point) []Point {
+	if !from.In(h.rg) || !to.In(h.rg) {
+		return nil
+	}
+	if from == to {
+		return []Point{from}
+	}
+	fromCluster, toCluster := h.clusterIndex(from), h.clusterIndex(to)
+
+	// Connect the start and goal positions to the abstract graph of their
+	// clusters. These edges are only used for this query.
+	startEdges := h.localEdgesFrom(fromCluster, from, h.clusters[fromCluster].nodes)
+	goalEdges := map[Point]hpaEdge{}
+	for _, n := range h.clusters[toCluster].nodes {
+		if edge, ok := h.localEdge(toCluster, n, to); ok {
+			goalEdges[n] = edge
+		}
+	}
+	if fromCluster == toCluster {
+		if edge, ok := h.localEdge(fromCluster, from, to); ok {
+			startEdges = append(startEdges, edge)
+		}
+	}
+
+	edgesOf := func(p Point) []hpaEdge {
+		edges := h.clusters[h.clusterIndex(p)].edges[p]
+		if p == from {
+			edges = append(edges[:len(edges):len(edges)], startEdges...)
+		}
+		if edge, ok := goalEdges[p]; ok {
+			edges = append(edges[:len(edges):len(edges)], edge)
+		}
+		return edges
+	}
+
+	// A* over the abstract graph.
+	nodes := map[Point]*node{}
+	via := map[Point]hpaEdge{}
+	get := func(p Point) *node {
+		n, ok := nodes[p]
+		if !ok {
+			n = &node{P: p, Cost: hpaUnreachable}
+			nodes[p] = n
+		}
+		return n
+	}
+	nq := &priorityQueue{}
+	fromNode := get(from)
+	fromNode.Cost = 0
+	fromNode.Open = true
+	fromNode.Estimation = h.ast.Estimation(from, to)
+	pqPush(nq, fromNode)
+	for nq.Len() > 0 {
+		n := pqPop(nq)
+		n.Open = false
+		n.Closed = true
+		if n.P == to {
+			return h.abstractPath(via, from, to)
+		}
+		for _, edge := range edgesOf(n.P) {
+			cost := n.Cost + edge.cost
+			nbNode := get(edge.to)
+			if cost >= nbNode.Cost {
+				continue
+			}
+			if nbNode.Open {
+				pqRemove(nq, nbNode.Idx)
+			}
+			nbNode.Cost = cost
+			nbNode.Open = true
+			nbNode.Closed = false
+			nbNode.Estimation = h.ast.Estimation(edge.to, to)
+			nbNode.Rank = cost + nbNode.Estimation
+			nbNode.Parent = n.P
+			via[edge.to] = edge
+			pqPush(nq, nbNode)
+		}
+	}
+	return nil
+}
+
+// hpaUnreachable is larger than any path cost.
+const hpaUnreachable = int(^uint(0) >> 2)
+
+// abstractPath concatenates the cached paths of the edges leading to the
+// goal.
+func (h *HPAStar) abstractPath(via map[Point]hpaEdge, from, to Point) []Point {
+	var edges []hpaEdge
+	for p := to; p != from; p = via[p].from {
+		edges = append(edges, via[p])
+	}
+	path := []Point{from}
+	for i := len(edges) - 1; i >= 0; i-- {
+		path = append(path, edges[i].path...)
+	}
+	return path
+}
+
+func (h *HPAStar) clusterCoords(p Point) Point {
+	return p.Sub(h.rg.Min).Div(h.clusterSize)
+}
+
+func (h *HPAStar) clusterIndex(p Point) int {
+	c := h.clusterCoords(p)
+	return c.Y*h.columns + c.X
+}
+
+// neighborClusters returns the indexes of the clusters sharing a border with
+// a cluster.
+func (h *HPAStar) neighborClusters(ci int) []int {
+	cx, cy := ci%h.columns, ci/h.columns
+	neighbors := make([]int, 0, 4)
+	if cx > 0 {
+		neighbors = append(neighbors, ci-1)
+	}
+	if cx < h.columns-1 {
+		neighbors = append(neighbors, ci+1)
+	}
+	if cy > 0 {
+		neighbors = append(neighbors, ci-h.columns)
+	}
+	if cy < h.rows-1 {
+		neighbors = append(neighbors, ci+h.columns)
+	}
+	return neighbors
+}
+
+// updateBorders finds the entrances on the borders of a cluster.
+func (h *HPAStar) updateBorders(ci int) {
+	for _, cj := range h.neighborClusters(ci) {
+		border := hpaBorder{A: ci, B: cj}
+		if cj < ci {
+			border = hpaBorder{A: cj, B: ci}
+		}
+		h.borders[border] = h.findEntrances(border)
+	}
+}
+
+// findEntrances scans a border for runs of positions that can be passed from
+// one cluster to the other.
+func (h *HPAStar) findEntrances(border hpaBorder) []hpaEntrance {
+	rgA := h.clusters[border.A].rg
+	var first, step, across Point
+	var length int
+	if border.B == border.A+1 {
+		// Vertical border, B is right of A.
+		first, step, across = Point{X: rgA.Max.X - 1, Y: rgA.Min.Y}, Point{Y: 1}, Point{X: 1}
+		length = rgA.Size().Y
+	} else {
+		// Horizontal border, B is below A.
+		first, step, across = Point{X: rgA.Min.X, Y: rgA.Max.Y - 1}, Point{X: 1}, Point{Y: 1}
+		length = rgA.Size().X
+	}
+	var entrances []hpaEntrance
+	addRun := func(start, end int) {
+		if end-start <= hpaMaxSingleEntrance {
+			a := first.Add(step.Mul((start + end - 1) / 2))
+			entrances = append(entrances, hpaEntrance{A: a, B: a.Add(across)})
+			return
+		}
+		for _, i := range []int{start, end - 1} {
+			a := first.Add(step.Mul(i))
+			entrances = append(entrances, hpaEntrance{A: a, B: a.Add(across)})
+		}
+	}
+	runStart := -1
+	for i := 0; i < length; i++ {
+		a := first.Add(step.Mul(i))
+		if h.isNeighbor(a, a.Add(across)) {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+		if runStart >= 0 {
+			addRun(runStart, i)
+			runStart = -1
+		}
+	}
+	if runStart >= 0 {
+		addRun(runStart, length)
+	}
+	return entrances
+}
+
+// isNeighbor returns true if each position is a neighbor of the other.
+func (h *HPAStar) isNeighbor(p, q Point) bool {
+	return h.hasNeighbor(p, q) && h.hasNeighbor(q, p)
+}
+
+func (h *HPAStar) hasNeighbor(p, q Point) bool {
+	for _, n := range h.ast.Neighbors(p) {
+		if n == q {
+			return true
+		}
+	}
+	return false
+}
+
+// buildCluster collects the abstract nodes of a cluster from the entrances on
+// its borders, and caches the paths between them.
+func (h *HPAStar) buildCluster(ci int) {
+	cluster := &h.clusters[ci]
+	cluster.nodes = cluster.nodes[:0]
+	cluster.edges = make(map[Point][]hpaEdge)
+	for _, cj := range h.neighborClusters(ci) {
+		border := hpaBorder{A: ci, B: cj}
+		if cj < ci {
+			border = hpaBorder{A: cj, B: ci}
+		}
+		for _, entrance := range h.borders[border] {
+			inside, outside := entrance.A, entrance.B
+			if cj < ci {
+				inside, outside = entrance.B, entrance.A
+			}
+			if _, ok := cluster.edges[inside]; !ok {
+				cluster.nodes = append(cluster.nodes, inside)
+				cluster.edges[inside] = nil
+			}
+			cluster.edges[inside] = append(cluster.edges[inside], hpaEdge{
+				from: inside,
+				to:   outside,
+				cost: h.ast.Cost(inside, outside),
+				path: []Point{outside},
+			})
+		}
+	}
+	for _, n := range cluster.nodes {
+		others := make([]Point, 0, len(cluster.nodes)-1)
+		for _, m := range cluster.nodes {
+			if m != n {
+				others = append(others, m)
+			}
+		}
+		cluster.edges[n] = append(cluster.edges[n], h.localEdgesFrom(ci, n, others)...)
+	}
+}
+
+// localEdgesFrom returns the edges from a position to the given positions of
+// the same cluster, using paths that stay within the cluster.
+func (h *HPAStar) localEdgesFrom(ci int, from Point, targets []Point) []hpaEdge {
+	edges := make([]hpaEdge, 0, len(targets))
+	for _, to := range targets {
+		if edge, ok := h.localEdge(ci, from, to); ok {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// localEdge searches a path from a position to another that stays within a
+// cluster.
+func (h *HPAStar) localEdge(ci int, from, to Point) (hpaEdge, bool) {
+	h.local.SetRange(h.clusters[ci].rg)
+	path := h.local.AstarPath(h.ast, from, to)
+	if path == nil {
+		return hpaEdge{}, false
+	}
+	cost := 0
+	for i := 1; i < len(path); i++ {
+		cost += h.ast.Cost(path[i-1], path[i])
+	}
+	return hpaEdge{from: from, to: to, cost: cost, path: path[1:]}, true
+}
@@ -0,0 +1,84 @@
+package geometry
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// testGridMap is a map with random walls. Like textiles.TileMap, it returns
+// the passable neighbors of walls too.
+type testGridMap struct {
+	rg        Rect
+	walls     map[Point]bool
+	diagonals bool
+	nb        Neighbors
+}
+
+func newTestGridMap(random *rand.Rand, w, h int, density float64) *testGridMap {
+	m := &testGridMap{rg: NewRect(0, 0, w, h), walls: make(map[Point]bool)}
+	for i := 0; i < int(float64(w*h)*density); i++ {
+		m.walls[Point{X: random.Intn(w), Y: random.Intn(h)}] = true
+	}
+	return m
+}
+
+func (m *testGridMap) passable(p Point) bool {
+	return p.In(m.rg) && !m.walls[p]
+}
+
+func (m *testGridMap) Neighbors(p Point) []Point {
+	if m.diagonals {
+		return m.nb.All(p, m.passable)
+	}
+	return m.nb.Cardinal(p, m.passable)
+}
+
+func (m *testGridMap) Cost(p, q Point) int {
+	return 1 + (p.X+q.X)%3
+}
+
+// pathCost returns the cost of a path, or -1 if it is nil.
+func (m *testGridMap) pathCost(path []Point) int {
+	if path == nil {
+		return -1
+	}
+	cost := 0
+	for i := 1; i < len(path); i++ {
+		cost += m.Cost(path[i-1], path[i])
+	}
+	return cost
+}
+
+func (m *testGridMap) Estimation(p, q Point) int {
+	if m.diagonals {
+		return DistanceChebyshev(p, q)
+	}
+	return DistanceManhattan(p, q)
+}
+
+func TestHPAStarReachability(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		m := newTestGridMap(random, 40, 30, 0.3)
+		m.diagonals = i%2 == 1
+		pr := NewPathRange(m.rg)
+		hpa := pr.HPAStar(m, 8)
+		for j := 0; j < 100; j++ {
+			from := Point{X: random.Intn(40), Y: random.Intn(30)}
+			to := Point{X: random.Intn(40), Y: random.Intn(30)}
+			if !m.passable(from) || !m.passable(to) {
+				continue
+			}
+			want := pr.AstarPath(m, from, to) != nil
+			path := hpa.Path(from, to)
+			if (path != nil) != want {
+				t.Fatalf("map %d, %v to %v: got path %v, want reachable %v", i, from, to, path, want)
+			}
+			for k := 1; k < len(path); k++ {
+				if !m.passable(path[k]) || DistanceChebyshev(path[k-1], path[k]) != 1 {
+					t.Fatalf("map %d, %v to %v: invalid path %v", i, from, to, path)
+				}
+			}
+		}
+	}
+}
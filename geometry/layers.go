@@ -0,0 +1,224 @@
+package geometry
+
+import "fmt"
+
+// Point3 is a position on a multi-level map: X and Y on a level, and Z the
+// level, for example the floor of a dungeon.
+type Point3 struct {
+	X int
+	Y int
+	Z int
+}
+
+// NewPoint3 returns the position of a point on a level.
+func NewPoint3(p Point, z int) Point3 {
+	return Point3{X: p.X, Y: p.Y, Z: z}
+}
+
+func (p Point3) String() string {
+	return fmt.Sprintf("(%d,%d,%d)", p.X, p.Y, p.Z)
+}
+
+// XY returns the position on the level.
+func (p Point3) XY() Point {
+	return Point{X: p.X, Y: p.Y}
+}
+
+// Add returns vector p+q.
+func (p Point3) Add(q Point3) Point3 {
+	return Point3{X: p.X + q.X, Y: p.Y + q.Y, Z: p.Z + q.Z}
+}
+
+// Sub returns vector p-q.
+func (p Point3) Sub(q Point3) Point3 {
+	return Point3{X: p.X - q.X, Y: p.Y - q.Y, Z: p.Z - q.Z}
+}
+
+// Layers describes a multi-level map made of Count levels of the same Size.
+// The levels are stacked vertically in a single Rect, so that every Point3
+// has a Point counterpart, and PathRange and the other algorithms working
+// on Points can be used unchanged on all levels at once: create the
+// PathRange with the Layers' Range, convert Point3 positions with ToPoint,
+// and the resulting paths with ToPoints3.
+type Layers struct {
+	Size  Point // size of each level
+	Count int   // number of levels
+}
+
+// NewLayers returns layers of count levels of a given size.
+func NewLayers(size Point, count int) Layers {
+	return Layers{Size: size, Count: count}
+}
+
+// Range returns the range of Points of all levels.
+func (l Layers) Range() Rect {
+	return NewRect(0, 0, l.Size.X, l.Size.Y*l.Count)
+}
+
+// LevelRange returns the range of Points of a level.
+func (l Layers) LevelRange(z int) Rect {
+	return NewRect(0, z*l.Size.Y, l.Size.X, (z+1)*l.Size.Y)
+}
+
+// Contains returns true if a position is on one of the levels.
+func (l Layers) Contains(p Point3) bool {
+	return p.X >= 0 && p.Y >= 0 && p.X < l.Size.X && p.Y < l.Size.Y && p.Z >= 0 && p.Z < l.Count
+}
+
+// ToPoint returns the Point of a position.
+func (l Layers) ToPoint(p Point3) Point {
+	return Point{X: p.X, Y: p.Z*l.Size.Y + p.Y}
+}
+
+// ToPoint3 returns the position of a Point.
+func (l Layers) ToPoint3(p Point) Point3 {
+	return Point3{X: p.X, Y: p.Y % l.Size.Y, Z: p.Y / l.Size.Y}
+}
+
+// ToPoints3 returns the positions of Points, for example a path returned by
+// PathRange.AstarPath.
+func (l Layers) ToPoints3(ps []Point) []Point3 {
+	ps3 := make([]Point3, len(ps))
+	for i, p := range ps {
+		ps3[i] = l.ToPoint3(p)
+	}
+	return ps3
+}
+
+// Portal is a one way connection between two positions which are not
+// adjacent, usually on different levels, such as stairs or a teleporter.
+type Portal struct {
+	From Point3
+	To   Point3
+	Cost int
+}
+
+// LayeredPather implements the Pather, Dijkstra and Astar interfaces on the
+// Points of Layers: it returns the adjacent positions on the same level,
+// and the destinations of the portals of a position.
+//
+// LayeredPather elements must be created with NewLayeredPather.
+type LayeredPather struct {
+	Layers    Layers
+	Passable  func(p Point3) bool
+	Diagonals bool // whether diagonal moves are allowed on levels
+
+	// StepCost returns the cost of a move between adjacent positions on a
+	// level. A nil StepCost costs 1 for all moves. Costs should be at least
+	// 1 for Estimation to be correct.
+	StepCost func(from, to Point3) int
+
+	portals map[Point3][]Portal
+	// planar is true while every portal costs at least the distance it
+	// covers on the levels, so that this distance is a valid estimation
+	planar bool
+	nb     Neighbors
+	ps     []Point
+}
+
+// NewLayeredPather returns a pather for layers with no portals. A nil
+// passable allows all positions.
+func NewLayeredPather(layers Layers, passable func(p Point3) bool, diagonals bool) *LayeredPather {
+	if passable == nil {
+		passable = func(Point3) bool { return true }
+	}
+	return &LayeredPather{
+		Layers:    layers,
+		Passable:  passable,
+		Diagonals: diagonals,
+		portals:   make(map[Point3][]Portal),
+		planar:    true,
+	}
+}
+
+// AddPortal adds a one way portal, such as a teleporter or a trap door.
+func (lp *LayeredPather) AddPortal(from, to Point3, cost int) {
+	lp.portals[from] = append(lp.portals[from], Portal{From: from, To: to, Cost: cost})
+	if cost < lp.planarDistance(from.XY(), to.XY()) {
+		lp.planar = false
+	}
+}
+
+// AddStairs adds portals in both directions between two positions.
+func (lp *LayeredPather) AddStairs(a, b Point3, cost int) {
+	lp.AddPortal(a, b, cost)
+	lp.AddPortal(b, a, cost)
+}
+
+// RemovePortals removes the portals starting from a position.
+func (lp *LayeredPather) RemovePortals(from Point3) {
+	delete(lp.portals, from)
+	lp.planar = true
+	for _, portals := range lp.portals {
+		for _, pt := range portals {
+			if pt.Cost < lp.planarDistance(pt.From.XY(), pt.To.XY()) {
+				lp.planar = false
+			}
+		}
+	}
+}
+
+// Portals returns the portals starting from a position.
+func (lp *LayeredPather) Portals(from Point3) []Portal {
+	return lp.portals[from]
+}
+
+// Neighbors implements Pather.Neighbors. The returned slice is cached, so it
+// is invalidated by the next call.
+func (lp *LayeredPather) Neighbors(p Point) []Point {
+	p3 := lp.Layers.ToPoint3(p)
+	level := lp.Layers.LevelRange(p3.Z)
+	keep := func(q Point) bool {
+		return q.In(level) && lp.Passable(lp.Layers.ToPoint3(q))
+	}
+	var ps []Point
+	if lp.Diagonals {
+		ps = lp.nb.All(p, keep)
+	} else {
+		ps = lp.nb.Cardinal(p, keep)
+	}
+	lp.ps = append(lp.ps[:0], ps...)
+	for _, pt := range lp.portals[p3] {
+		if lp.Layers.Contains(pt.To) && lp.Passable(pt.To) {
+			lp.ps = append(lp.ps, lp.Layers.ToPoint(pt.To))
+		}
+	}
+	return lp.ps
+}
+
+// Cost implements Dijkstra.Cost. It returns the cost of the cheapest portal
+// from a position to another if any, and the StepCost otherwise.
+func (lp *LayeredPather) Cost(from, to Point) int {
+	from3, to3 := lp.Layers.ToPoint3(from), lp.Layers.ToPoint3(to)
+	cost := -1
+	for _, pt := range lp.portals[from3] {
+		if pt.To == to3 && (cost < 0 || pt.Cost < cost) {
+			cost = pt.Cost
+		}
+	}
+	if cost >= 0 {
+		return cost
+	}
+	if lp.StepCost == nil {
+		return 1
+	}
+	return lp.StepCost(from3, to3)
+}
+
+// Estimation implements Astar.Estimation. It returns the distance on the
+// levels, ignoring the level difference, as long as every portal costs at
+// least the distance it covers on the levels, and 0 otherwise, so that
+// AstarPath still finds the shortest paths, at the cost of exploring more.
+func (lp *LayeredPather) Estimation(from, to Point) int {
+	if !lp.planar {
+		return 0
+	}
+	return lp.planarDistance(lp.Layers.ToPoint3(from).XY(), lp.Layers.ToPoint3(to).XY())
+}
+
+func (lp *LayeredPather) planarDistance(p, q Point) int {
+	if lp.Diagonals {
+		return DistanceChebyshev(p, q)
+	}
+	return DistanceManhattan(p, q)
+}
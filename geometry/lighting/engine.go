@@ -0,0 +1,297 @@
+package lighting
+
+import (
+	"github.com/memmaker/go/geometry"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// Engine accumulates the light of several colored sources in a light buffer.
+// Unlike FOV.LightMap, which only tells whether a position is reached by any
+// source, every source keeps its own contribution, so that changing, moving,
+// removing or flickering a light only recomputes that light.
+//
+// Changes are recorded by Add, Set, Move, Remove and Invalidate, and applied
+// by Update, so that several changes can be batched before a redraw.
+type Engine struct {
+	rg      geometry.Rect
+	lt      geometry.Lighter
+	fov     *geometry.FOV
+	ambient color.RGBA
+	buffer  []rgb // accumulated light per position of rg, row by row
+	lights  map[LightID]*lightState
+	nextID  LightID
+}
+
+type rgb struct {
+	R, G, B float64
+}
+
+type lightState struct {
+	Light
+	flicker float64     // current intensity factor from flickering
+	cells   []lightCell // contribution without color and flicker
+	applied rgb         // color and flicker of the contribution in the buffer
+	dirty   bool
+	removed bool
+}
+
+type lightCell struct {
+	idx    int
+	amount float64
+}
+
+// NewEngine returns a lighting engine for a range of positions. Light rays
+// are propagated using the given Lighter, as with FOV.VisionMap, for example
+// a textiles.TileMap's AsLighter. Rays are further limited by each light's
+// radius.
+func NewEngine(rg geometry.Rect, lt geometry.Lighter) *Engine {
+	size := rg.Size()
+	return &Engine{
+		rg:     rg,
+		lt:     lt,
+		fov:    geometry.NewFOV(rg),
+		buffer: make([]rgb, size.X*size.Y),
+		lights: make(map[LightID]*lightState),
+	}
+}
+
+// Range returns the range of positions of the light buffer.
+func (e *Engine) Range() geometry.Rect {
+	return e.rg
+}
+
+// SetAmbient sets the light color of positions not reached by any light. It
+// is added to the light of every position.
+func (e *Engine) SetAmbient(c color.RGBA) {
+	e.ambient = c
+}
+
+// Ambient returns the ambient light color.
+func (e *Engine) Ambient() color.RGBA {
+	return e.ambient
+}
+
+// Add adds a light and returns its identifier.
+func (e *Engine) Add(l Light) LightID {
+	id := e.nextID
+	e.nextID++
+	e.lights[id] = &lightState{Light: l, flicker: 1, dirty: true}
+	return id
+}
+
+// Get returns a light.
+func (e *Engine) Get(id LightID) (Light, bool) {
+	st, ok := e.lights[id]
+	if !ok || st.removed {
+		return Light{}, false
+	}
+	return st.Light, true
+}
+
+// Set replaces a light. It returns false if there is no such light.
+func (e *Engine) Set(id LightID, l Light) bool {
+	st, ok := e.lights[id]
+	if !ok || st.removed {
+		return false
+	}
+	st.Light = l
+	st.dirty = true
+	return true
+}
+
+// Move moves a light to a new position. It returns false if there is no
+// such light.
+func (e *Engine) Move(id LightID, p geometry.Point) bool {
+	st, ok := e.lights[id]
+	if !ok || st.removed {
+		return false
+	}
+	if st.Pos != p {
+		st.Pos = p
+		st.dirty = true
+	}
+	return true
+}
+
+// Remove removes a light. It returns false if there is no such light.
+func (e *Engine) Remove(id LightID) bool {
+	st, ok := e.lights[id]
+	if !ok || st.removed {
+		return false
+	}
+	st.removed = true
+	st.dirty = true
+	return true
+}
+
+// Len returns the number of lights.
+func (e *Engine) Len() int {
+	n := 0
+	for _, st := range e.lights {
+		if !st.removed {
+			n++
+		}
+	}
+	return n
+}
+
+// Invalidate marks the lights which may reach any of the given positions
+// for recomputation. It should be called when the terrain at those
+// positions changes, for example when a door opens.
+func (e *Engine) Invalidate(ps ...geometry.Point) {
+	for _, st := range e.lights {
+		if st.dirty {
+			continue
+		}
+		for _, p := range ps {
+			if float64(geometry.DistanceChebyshev(p, st.Pos)) <= st.Radius {
+				st.dirty = true
+				break
+			}
+		}
+	}
+}
+
+// InvalidateAll marks all lights for recomputation.
+func (e *Engine) InvalidateAll() {
+	for _, st := range e.lights {
+		st.dirty = true
+	}
+}
+
+// Flicker draws new random intensities for the lights with a non-zero
+// Flicker amplitude. Flickering does not recompute light rays, so it is
+// cheap enough to be called every frame.
+func (e *Engine) Flicker(random *rand.Rand) {
+	for _, st := range e.lights {
+		if st.removed || st.Flicker <= 0 {
+			continue
+		}
+		factor := 1 - st.Flicker*random.Float64()
+		if st.dirty {
+			st.flicker = factor
+			continue
+		}
+		e.remove(st)
+		st.flicker = factor
+		e.add(st)
+	}
+}
+
+// Update recomputes the lights changed since the last call and updates the
+// light buffer. It returns the number of recomputed lights.
+func (e *Engine) Update() int {
+	n := 0
+	for id, st := range e.lights {
+		if !st.dirty {
+			continue
+		}
+		n++
+		e.remove(st)
+		if st.removed {
+			delete(e.lights, id)
+			continue
+		}
+		e.compute(st)
+		e.add(st)
+		st.dirty = false
+	}
+	return n
+}
+
+// compute propagates a light and stores its contribution.
+func (e *Engine) compute(st *lightState) {
+	st.cells = st.cells[:0]
+	if st.Radius <= 0 || st.Intensity <= 0 || !st.Pos.In(e.rg) {
+		return
+	}
+	lt := radiusLighter{Lighter: e.lt, radius: st.Radius}
+	// VisionMap also returns positions reached beyond the max cost, such
+	// as those behind walls, which get no light.
+	maxCost := lt.MaxCost(st.Pos)
+	for _, n := range e.fov.VisionMap(lt, st.Pos) {
+		if n.Cost > maxCost {
+			continue
+		}
+		amount := st.at(n.Cost)
+		if amount <= 0 {
+			continue
+		}
+		st.cells = append(st.cells, lightCell{idx: e.idx(n.P), amount: amount})
+	}
+}
+
+// add adds the contribution of a light to the light buffer.
+func (e *Engine) add(st *lightState) {
+	k := st.flicker / 255
+	st.applied = rgb{R: k * float64(st.Color.R), G: k * float64(st.Color.G), B: k * float64(st.Color.B)}
+	e.accumulate(st.cells, st.applied, 1)
+}
+
+// remove removes the contribution of a light from the light buffer, as it
+// was added, even if the light changed since.
+func (e *Engine) remove(st *lightState) {
+	e.accumulate(st.cells, st.applied, -1)
+}
+
+func (e *Engine) accumulate(cells []lightCell, c rgb, sign float64) {
+	for _, cell := range cells {
+		v := &e.buffer[cell.idx]
+		k := sign * cell.amount
+		v.R += k * c.R
+		v.G += k * c.G
+		v.B += k * c.B
+	}
+}
+
+func (e *Engine) idx(p geometry.Point) int {
+	p = p.Sub(e.rg.Min)
+	return p.Y*e.rg.Size().X + p.X
+}
+
+// At returns the light color at a position, including the ambient light.
+// Channels saturate at 255.
+func (e *Engine) At(p geometry.Point) color.RGBA {
+	if !p.In(e.rg) {
+		return e.ambient
+	}
+	v := e.buffer[e.idx(p)]
+	return color.RGBA{
+		R: channel(float64(e.ambient.R)/255 + v.R),
+		G: channel(float64(e.ambient.G)/255 + v.G),
+		B: channel(float64(e.ambient.B)/255 + v.B),
+		A: 255,
+	}
+}
+
+// Brightness returns the light level at a position, without the ambient
+// light, as the brightest channel from 0 (dark) to 1 (full). It can exceed
+// 1 where several lights overlap.
+func (e *Engine) Brightness(p geometry.Point) float64 {
+	if !p.In(e.rg) {
+		return 0
+	}
+	v := e.buffer[e.idx(p)]
+	return math.Max(0, math.Max(v.R, math.Max(v.G, v.B)))
+}
+
+// IsLit returns true if any light reaches a position.
+func (e *Engine) IsLit(p geometry.Point) bool {
+	return e.Brightness(p) > 1.0/512
+}
+
+func channel(v float64) uint8 {
+	return uint8(math.Round(255 * math.Max(0, math.Min(1, v))))
+}
+
+// radiusLighter limits the light rays of a Lighter to a radius.
+type radiusLighter struct {
+	geometry.Lighter
+	radius float64
+}
+
+func (l radiusLighter) MaxCost(src geometry.Point) float64 {
+	return math.Min(l.radius, l.Lighter.MaxCost(src))
+}
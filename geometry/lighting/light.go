@@ -0,0 +1,70 @@
+// Package lighting implements colored, additive lighting on top of the
+// geometry FOV: each light source is propagated with a VisionMap, attenuated
+// by a falloff curve, and accumulated per position in a light buffer which
+// can then be used to tint textiles.TextIcon colors.
+package lighting
+
+import (
+	"github.com/memmaker/go/geometry"
+	"image/color"
+	"math"
+)
+
+// Falloff maps the relative distance of a position to its light source, from
+// 0 at the source to 1 at the light's radius, to an intensity factor,
+// usually from 1 down to 0.
+type Falloff func(t float64) float64
+
+// FalloffConstant lights the whole radius evenly.
+func FalloffConstant(t float64) float64 {
+	return 1
+}
+
+// FalloffLinear decreases the intensity linearly with the distance.
+func FalloffLinear(t float64) float64 {
+	return 1 - t
+}
+
+// FalloffQuadratic decreases the intensity quickly near the source and
+// slowly near the radius.
+func FalloffQuadratic(t float64) float64 {
+	return (1 - t) * (1 - t)
+}
+
+// FalloffSmooth keeps the intensity high near the source and fades it out
+// smoothly near the radius.
+func FalloffSmooth(t float64) float64 {
+	return 1 - t*t*(3-2*t)
+}
+
+// FalloffInverseSquare approximates physical light, bounded so that the
+// intensity is 1 at the source and 0 at the radius.
+func FalloffInverseSquare(t float64) float64 {
+	const k = 8
+	return math.Max(0, (1/(1+k*t*t)-1/(1+k))/(1-1/(1+k)))
+}
+
+// Light is a colored light source.
+type Light struct {
+	Pos       geometry.Point
+	Color     color.RGBA
+	Radius    float64 // maximal light ray cost, see geometry.Lighter
+	Intensity float64 // brightness at the source, 1 is the full color
+	Falloff   Falloff // nil means FalloffLinear
+	Flicker   float64 // amplitude of random intensity changes by Engine.Flicker, from 0 to 1
+}
+
+// LightID identifies a light in an Engine.
+type LightID int
+
+// at returns the light's intensity at a given ray cost from the source.
+func (l Light) at(cost float64) float64 {
+	if l.Radius <= 0 || cost > l.Radius {
+		return 0
+	}
+	falloff := l.Falloff
+	if falloff == nil {
+		falloff = FalloffLinear
+	}
+	return l.Intensity * falloff(cost/l.Radius)
+}
@@ -0,0 +1,38 @@
+package lighting
+
+import (
+	"github.com/memmaker/go/fxtools"
+	"github.com/memmaker/go/geometry"
+	"github.com/memmaker/go/textiles"
+	"image/color"
+)
+
+// TintColor returns a color lit by a light color: every channel is scaled
+// by the light's channel, and the result is blended with the original color
+// by strength, from 0 (unchanged) to 1 (fully lit). The alpha channel is
+// kept.
+func TintColor(c, light color.RGBA, strength float64) color.RGBA {
+	lit := color.RGBA{
+		R: uint8(int(c.R) * int(light.R) / 255),
+		G: uint8(int(c.G) * int(light.G) / 255),
+		B: uint8(int(c.B) * int(light.B) / 255),
+		A: c.A,
+	}
+	return fxtools.LerpColorRGBA(c, lit, strength)
+}
+
+// TintIcon returns an icon with its foreground and background lit by a light
+// color, see TintColor. Transparent backgrounds are kept.
+func TintIcon(icon textiles.TextIcon, light color.RGBA, strength float64) textiles.TextIcon {
+	fg := TintColor(icon.Fg, light, strength)
+	bg := icon.Bg
+	if icon.HasBackground() {
+		bg = TintColor(bg, light, strength)
+	}
+	return icon.WithColors(fg, bg)
+}
+
+// Tint returns an icon drawn at a position, lit by the light there.
+func (e *Engine) Tint(p geometry.Point, icon textiles.TextIcon) textiles.TextIcon {
+	return TintIcon(icon, e.At(p), 1)
+}
@@ -0,0 +1,89 @@
+package mapgen
+
+import (
+	"math/rand"
+
+	"github.com/memmaker/go/geometry"
+)
+
+// BSPOptions configures BSP.
+type BSPOptions struct {
+	MinLeafSize int // minimal width and height of a partition
+	MinRoomSize int // minimal width and height of a room
+	RoomPadding int // minimal distance between a room and its partition edge
+}
+
+// DefaultBSPOptions returns the options used for a zero BSPOptions.
+func DefaultBSPOptions() BSPOptions {
+	return BSPOptions{MinLeafSize: 8, MinRoomSize: 3, RoomPadding: 1}
+}
+
+// BSP generates rooms by recursively splitting the map in two partitions,
+// placing a room in each final partition, and connecting sibling partitions
+// with corridors. It returns the grid and the rooms.
+func BSP(random *rand.Rand, size geometry.Point, options BSPOptions) (*Grid, []geometry.Rect) {
+	if options == (BSPOptions{}) {
+		options = DefaultBSPOptions()
+	}
+	options.MinRoomSize = max(options.MinRoomSize, 1)
+	options.MinLeafSize = max(options.MinLeafSize, options.MinRoomSize+2*options.RoomPadding)
+	g := NewGrid(size, Wall)
+	var rooms []geometry.Rect
+	splitBSP(random, g, g.interior(), options, &rooms)
+	Connect(random, g)
+	return g, rooms
+}
+
+// splitBSP splits a partition, or places a room in it if it is too small to
+// be split. It returns the rooms placed in the partition.
+func splitBSP(random *rand.Rand, g *Grid, rg geometry.Rect, options BSPOptions, rooms *[]geometry.Rect) []geometry.Rect {
+	size := rg.Size()
+	canSplitColumns := size.X >= 2*options.MinLeafSize
+	canSplitLines := size.Y >= 2*options.MinLeafSize
+	if !canSplitColumns && !canSplitLines {
+		room := placeBSPRoom(random, rg, options)
+		g.Fill(room, Floor)
+		*rooms = append(*rooms, room)
+		return []geometry.Rect{room}
+	}
+	splitColumns := canSplitColumns
+	if canSplitColumns && canSplitLines {
+		// Prefer splitting the longer side, to avoid very thin partitions.
+		switch {
+		case size.X > size.Y*5/4:
+			splitColumns = true
+		case size.Y > size.X*5/4:
+			splitColumns = false
+		default:
+			splitColumns = random.Intn(2) == 0
+		}
+	}
+	var a, b geometry.Rect
+	if splitColumns {
+		at := options.MinLeafSize + random.Intn(size.X-2*options.MinLeafSize+1)
+		a, b = rg.BisectAtColumn(at)
+	} else {
+		at := options.MinLeafSize + random.Intn(size.Y-2*options.MinLeafSize+1)
+		a, b = rg.BisectAtLine(at)
+	}
+	roomsA := splitBSP(random, g, a, options, rooms)
+	roomsB := splitBSP(random, g, b, options, rooms)
+	roomA := roomsA[random.Intn(len(roomsA))]
+	roomB := roomsB[random.Intn(len(roomsB))]
+	carveCorridor(random, g, roomA.Center(), roomB.Center())
+	return append(roomsA, roomsB...)
+}
+
+func placeBSPRoom(random *rand.Rand, rg geometry.Rect, options BSPOptions) geometry.Rect {
+	inner := geometry.NewRect(
+		rg.Min.X+options.RoomPadding, rg.Min.Y+options.RoomPadding,
+		rg.Max.X-options.RoomPadding, rg.Max.Y-options.RoomPadding,
+	)
+	size := inner.Size()
+	w := options.MinRoomSize + random.Intn(max(size.X-options.MinRoomSize, 0)+1)
+	h := options.MinRoomSize + random.Intn(max(size.Y-options.MinRoomSize, 0)+1)
+	w, h = min(w, size.X), min(h, size.Y)
+	x := inner.Min.X + random.Intn(size.X-w+1)
+	y := inner.Min.Y + random.Intn(size.Y-h+1)
+	return geometry.NewRect(x, y, x+w, y+h)
+}
@@ -0,0 +1,102 @@
+package mapgen
+
+import (
+	"math/rand"
+
+	"github.com/memmaker/go/geometry"
+)
+
+// CaveOptions configures Caves.
+type CaveOptions struct {
+	FillProbability float64 // initial probability of a cell being a wall
+	Iterations      int     // number of smoothing steps
+	BirthLimit      int     // a floor becomes a wall with more wall neighbors
+	SurvivalLimit   int     // a wall stays a wall with at least this many wall neighbors
+	KeepLargestOnly bool    // fill disconnected caves instead of connecting them
+}
+
+// DefaultCaveOptions returns the options used for a zero CaveOptions.
+func DefaultCaveOptions() CaveOptions {
+	return CaveOptions{FillProbability: 0.45, Iterations: 5, BirthLimit: 4, SurvivalLimit: 4}
+}
+
+// Caves generates organic caves with a cellular automaton: the map starts as
+// random noise, and is smoothed by turning cells into walls when enough of
+// their eight neighbors are walls.
+func Caves(random *rand.Rand, size geometry.Point, options CaveOptions) *Grid {
+	if options == (CaveOptions{}) {
+		options = DefaultCaveOptions()
+	}
+	g := NewGrid(size, Wall)
+	interior := g.interior()
+	interior.Iter(func(p geometry.Point) {
+		if random.Float64() >= options.FillProbability {
+			g.Set(p, Floor)
+		}
+	})
+	next := NewGrid(size, Wall)
+	for i := 0; i < options.Iterations; i++ {
+		interior.Iter(func(p geometry.Point) {
+			walls := countWallsAround(g, p)
+			switch {
+			case g.At(p) == Wall && walls >= options.SurvivalLimit:
+				next.Set(p, Wall)
+			case g.At(p) != Wall && walls > options.BirthLimit:
+				next.Set(p, Wall)
+			default:
+				next.Set(p, Floor)
+			}
+		})
+		g.Cells, next.Cells = next.Cells, g.Cells
+	}
+	if options.KeepLargestOnly {
+		KeepLargestComponent(g)
+	} else {
+		Connect(random, g)
+	}
+	return g
+}
+
+func countWallsAround(g *Grid, p geometry.Point) int {
+	count := 0
+	for y := -1; y <= 1; y++ {
+		for x := -1; x <= 1; x++ {
+			if (x != 0 || y != 0) && g.At(p.Shift(x, y)) == Wall {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// DrunkardsWalk generates caves by letting a random walker dig through the
+// map until the given fraction of the interior is floor. The walker starts
+// in the center; everything it digs is connected by construction.
+func DrunkardsWalk(random *rand.Rand, size geometry.Point, coverage float64) *Grid {
+	g := NewGrid(size, Wall)
+	interior := g.interior()
+	if interior.Empty() {
+		return g
+	}
+	area := interior.Size()
+	target := int(coverage * float64(area.X*area.Y))
+	directions := []geometry.Point{
+		geometry.RelativeNorth, geometry.RelativeEast, geometry.RelativeSouth, geometry.RelativeWest,
+	}
+	p := interior.Center()
+	g.Set(p, Floor)
+	dug := 1
+	// The walk is bounded, so that it ends even for unreachable coverages.
+	for steps := 0; dug < target && steps < 100*area.X*area.Y; steps++ {
+		q := p.Add(directions[random.Intn(len(directions))])
+		if !q.In(interior) {
+			continue
+		}
+		p = q
+		if g.At(p) == Wall {
+			g.Set(p, Floor)
+			dug++
+		}
+	}
+	return g
+}
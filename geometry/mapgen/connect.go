@@ -0,0 +1,178 @@
+package mapgen
+
+import (
+	"math/rand"
+
+	"github.com/memmaker/go/geometry"
+)
+
+// Components returns the connected components of passable cells, largest
+// first.
+func Components(g *Grid) [][]geometry.Point {
+	pr := geometry.NewPathRange(g.Bounds())
+	pr.CCMapAll(g)
+	byID := map[int][]geometry.Point{}
+	var ids []int
+	g.Bounds().Iter(func(p geometry.Point) {
+		if !g.IsPassable(p) {
+			return
+		}
+		id := pr.CCMapAt(p)
+		if _, ok := byID[id]; !ok {
+			ids = append(ids, id)
+		}
+		byID[id] = append(byID[id], p)
+	})
+	components := make([][]geometry.Point, 0, len(ids))
+	for _, id := range ids {
+		components = append(components, byID[id])
+	}
+	// Insertion sort keeps the order stable for equal sizes, so that the
+	// result only depends on the grid.
+	for i := 1; i < len(components); i++ {
+		for j := i; j > 0 && len(components[j]) > len(components[j-1]); j-- {
+			components[j], components[j-1] = components[j-1], components[j]
+		}
+	}
+	return components
+}
+
+// IsConnected reports whether all passable cells form a single connected
+// component.
+func IsConnected(g *Grid) bool {
+	passable := g.CountPassable()
+	if passable == 0 {
+		return true
+	}
+	start := geometry.Point{}
+	for i := range g.Cells {
+		if p := (geometry.Point{X: i % g.Size.X, Y: i / g.Size.X}); g.IsPassable(p) {
+			start = p
+			break
+		}
+	}
+	pr := geometry.NewPathRange(g.Bounds())
+	return len(pr.CCMap(g, start)) == passable
+}
+
+// Connect digs corridors between the connected components of the grid until
+// all passable cells are connected. Each smaller component is joined to the
+// largest one, from the closest pair of sampled cells. Corridors run through
+// the interior of the grid, and only dig the outer border to reach cells on
+// it.
+func Connect(random *rand.Rand, g *Grid) {
+	for {
+		components := Components(g)
+		if len(components) < 2 {
+			return
+		}
+		main := components[0]
+		carved := 0
+		for _, component := range components[1:] {
+			from, to := closestPair(random, component, main)
+			carved += carveCorridor(random, g, from, to)
+		}
+		// Every corridor is a floor path between its component and the main
+		// one, so a pass digging nothing means that all are connected.
+		if carved == 0 {
+			return
+		}
+	}
+}
+
+// KeepLargestComponent fills every passable cell outside of the largest
+// connected component with walls.
+func KeepLargestComponent(g *Grid) {
+	components := Components(g)
+	for _, component := range components[min(1, len(components)):] {
+		for _, p := range component {
+			g.Set(p, Wall)
+		}
+	}
+}
+
+// closestPair returns a pair of close cells from two sets, comparing a
+// bounded number of random samples from each.
+func closestPair(random *rand.Rand, a, b []geometry.Point) (geometry.Point, geometry.Point) {
+	const samples = 24
+	sample := func(ps []geometry.Point) []geometry.Point {
+		if len(ps) <= samples {
+			return ps
+		}
+		picked := make([]geometry.Point, samples)
+		for i := range picked {
+			picked[i] = ps[random.Intn(len(ps))]
+		}
+		return picked
+	}
+	as, bs := sample(a), sample(b)
+	bestA, bestB, best := as[0], bs[0], -1
+	for _, p := range as {
+		for _, q := range bs {
+			if d := geometry.DistanceManhattan(p, q); best < 0 || d < best {
+				bestA, bestB, best = p, q, d
+			}
+		}
+	}
+	return bestA, bestB
+}
+
+// carveCorridor digs an L-shaped corridor between two positions through the
+// interior of the grid, keeping its outer border but for the cells needed to
+// reach positions on the border. It returns the number of dug cells.
+func carveCorridor(random *rand.Rand, g *Grid, from, to geometry.Point) int {
+	interior := g.interior()
+	if interior.Empty() {
+		return carveL(random, g, g.Bounds(), from, to)
+	}
+	a, b := clampTo(interior, from), clampTo(interior, to)
+	carved := carveL(random, g, interior, a, b)
+	if a != from {
+		carved += carveL(random, g, g.Bounds(), from, a)
+	}
+	if b != to {
+		carved += carveL(random, g, g.Bounds(), b, to)
+	}
+	return carved
+}
+
+// carveL digs an L-shaped corridor between two positions, in a range.
+func carveL(random *rand.Rand, g *Grid, rg geometry.Rect, from, to geometry.Point) int {
+	corner := geometry.Point{X: to.X, Y: from.Y}
+	if random.Intn(2) == 0 {
+		corner = geometry.Point{X: from.X, Y: to.Y}
+	}
+	return carveLine(g, rg, from, corner) + carveLine(g, rg, corner, to)
+}
+
+func carveLine(g *Grid, rg geometry.Rect, from, to geometry.Point) int {
+	step := geometry.Point{X: sign(to.X - from.X), Y: sign(to.Y - from.Y)}
+	carved := 0
+	for p := from; ; p = p.Add(step) {
+		if p.In(rg) && !g.IsPassable(p) {
+			g.Set(p, Floor)
+			carved++
+		}
+		if p == to {
+			return carved
+		}
+	}
+}
+
+// clampTo returns the position of a non-empty range closest to p.
+func clampTo(rg geometry.Rect, p geometry.Point) geometry.Point {
+	return geometry.Point{
+		X: max(rg.Min.X, min(p.X, rg.Max.X-1)),
+		Y: max(rg.Min.Y, min(p.Y, rg.Max.Y-1)),
+	}
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
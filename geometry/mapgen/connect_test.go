@@ -0,0 +1,72 @@
+package mapgen
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/memmaker/go/geometry"
+)
+
+func TestConnectBorderFloors(t *testing.T) {
+	g := NewGrid(geometry.Point{X: 5, Y: 5}, Wall)
+	g.Set(geometry.Point{X: 0, Y: 1}, Floor)
+	g.Set(geometry.Point{X: 0, Y: 3}, Floor)
+	g.Set(geometry.Point{X: 4, Y: 4}, Floor)
+	Connect(rand.New(rand.NewSource(1)), g)
+	if !IsConnected(g) {
+		t.Fatalf("grid not connected:\n%s", g)
+	}
+	for _, p := range []geometry.Point{{X: 0, Y: 1}, {X: 0, Y: 3}, {X: 4, Y: 4}} {
+		if !g.IsPassable(p) {
+			t.Errorf("floor at %v was filled", p)
+		}
+	}
+}
+
+func TestConnectWithoutInterior(t *testing.T) {
+	for _, size := range []geometry.Point{{X: 1, Y: 5}, {X: 2, Y: 2}, {X: 5, Y: 2}, {X: 1, Y: 1}} {
+		g := NewGrid(size, Wall)
+		g.Set(geometry.Point{}, Floor)
+		g.Set(size.Sub(geometry.Point{X: 1, Y: 1}), Floor)
+		Connect(rand.New(rand.NewSource(1)), g)
+		if !IsConnected(g) {
+			t.Errorf("grid of size %v not connected:\n%s", size, g)
+		}
+	}
+}
+
+func TestConnectRandomGrids(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		size := geometry.Point{X: 1 + random.Intn(12), Y: 1 + random.Intn(12)}
+		g := NewGrid(size, Wall)
+		g.Bounds().Iter(func(p geometry.Point) {
+			if random.Intn(4) == 0 {
+				g.Set(p, Floor)
+			}
+		})
+		Connect(random, g)
+		if !IsConnected(g) {
+			t.Fatalf("grid of size %v not connected:\n%s", size, g)
+		}
+	}
+}
+
+func TestConnectOtherCells(t *testing.T) {
+	// Cells other than Floor and Door, such as water, are not passable.
+	const water Cell = 3
+	g := NewGrid(geometry.Point{X: 7, Y: 5}, water)
+	g.Set(geometry.Point{X: 1, Y: 2}, Floor)
+	g.Set(geometry.Point{X: 2, Y: 2}, Door)
+	if !IsConnected(g) || g.CountPassable() != 2 {
+		t.Errorf("floor and door not connected:\n%s", g)
+	}
+	g.Set(geometry.Point{X: 5, Y: 2}, Floor)
+	if IsConnected(g) {
+		t.Errorf("floors separated by water reported connected:\n%s", g)
+	}
+	Connect(rand.New(rand.NewSource(1)), g)
+	if !IsConnected(g) {
+		t.Errorf("grid not connected:\n%s", g)
+	}
+}
@@ -0,0 +1,147 @@
+// Package mapgen provides dungeon generators: BSP room splitting, cellular
+// automata caves, drunkard's walk and prefab rooms connected by corridors.
+//
+// All generators take a seeded *rand.Rand, so that the same seed always
+// produces the same map, and return a Grid whose passable cells form a
+// single connected component.
+package mapgen
+
+import (
+	"strings"
+
+	"github.com/memmaker/go/geometry"
+)
+
+// Cell is the content of a grid cell.
+type Cell int16
+
+const (
+	Wall Cell = iota
+	Floor
+	Door
+)
+
+// Grid is a rectangular map of cells, starting at (0,0). It implements
+// geometry.Pather with cardinal movement between passable cells.
+type Grid struct {
+	Size  geometry.Point
+	Cells []Cell
+	nb    geometry.Neighbors
+}
+
+// NewGrid returns a grid of the given size with every cell set to fill.
+func NewGrid(size geometry.Point, fill Cell) *Grid {
+	g := &Grid{Size: size, Cells: make([]Cell, size.X*size.Y)}
+	for i := range g.Cells {
+		g.Cells[i] = fill
+	}
+	return g
+}
+
+// Bounds returns the range of the grid.
+func (g *Grid) Bounds() geometry.Rect {
+	return geometry.NewRect(0, 0, g.Size.X, g.Size.Y)
+}
+
+func (g *Grid) Contains(p geometry.Point) bool {
+	return p.X >= 0 && p.Y >= 0 && p.X < g.Size.X && p.Y < g.Size.Y
+}
+
+// At returns the cell at a position, or Wall outside of the grid.
+func (g *Grid) At(p geometry.Point) Cell {
+	if !g.Contains(p) {
+		return Wall
+	}
+	return g.Cells[p.Y*g.Size.X+p.X]
+}
+
+func (g *Grid) Set(p geometry.Point, cell Cell) {
+	if g.Contains(p) {
+		g.Cells[p.Y*g.Size.X+p.X] = cell
+	}
+}
+
+// Fill sets every cell of a range.
+func (g *Grid) Fill(rg geometry.Rect, cell Cell) {
+	rg.Intersect(g.Bounds()).Iter(func(p geometry.Point) {
+		g.Set(p, cell)
+	})
+}
+
+func (g *Grid) IsPassable(p geometry.Point) bool {
+	cell := g.At(p)
+	return cell == Floor || cell == Door
+}
+
+// Neighbors implements geometry.Pather. It returns the passable cardinal
+// neighbors of passable positions, and nothing for walls.
+func (g *Grid) Neighbors(p geometry.Point) []geometry.Point {
+	if !g.IsPassable(p) {
+		return nil
+	}
+	return g.nb.Cardinal(p, g.IsPassable)
+}
+
+// CountPassable returns the number of passable cells.
+func (g *Grid) CountPassable() int {
+	count := 0
+	g.Bounds().Iter(func(p geometry.Point) {
+		if g.IsPassable(p) {
+			count++
+		}
+	})
+	return count
+}
+
+// TileIndices maps the cells to tile indices, eg. for textiles.TileMap.
+func (g *Grid) TileIndices(tiles map[Cell]int16) []int16 {
+	indices := make([]int16, len(g.Cells))
+	for i, cell := range g.Cells {
+		indices[i] = tiles[cell]
+	}
+	return indices
+}
+
+// String returns the grid as text, with '#' for walls, '.' for floors and
+// '+' for doors.
+func (g *Grid) String() string {
+	var sb strings.Builder
+	for y := 0; y < g.Size.Y; y++ {
+		for x := 0; x < g.Size.X; x++ {
+			sb.WriteRune(g.At(geometry.Point{X: x, Y: y}).Rune())
+		}
+		sb.WriteRune('\n')
+	}
+	return sb.String()
+}
+
+func (c Cell) Rune() rune {
+	switch c {
+	case Floor:
+		return '.'
+	case Door:
+		return '+'
+	}
+	return '#'
+}
+
+// CellFromRune is the inverse of Cell.Rune. Unknown runes are walls.
+func CellFromRune(r rune) Cell {
+	switch r {
+	case '.':
+		return Floor
+	case '+':
+		return Door
+	}
+	return Wall
+}
+
+// interior returns the grid range without its outer border, which the
+// generators keep as walls. It is empty for grids less than 3 cells wide or
+// high.
+func (g *Grid) interior() geometry.Rect {
+	if g.Size.X < 3 || g.Size.Y < 3 {
+		return geometry.Rect{}
+	}
+	return geometry.NewRect(1, 1, g.Size.X-1, g.Size.Y-1)
+}
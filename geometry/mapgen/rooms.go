@@ -0,0 +1,157 @@
+package mapgen
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/memmaker/go/geometry"
+)
+
+// RoomOptions configures RoomsAndCorridors.
+type RoomOptions struct {
+	MaxRooms    int
+	MinRoomSize int
+	MaxRoomSize int
+	Attempts    int // number of placement attempts per room
+}
+
+// DefaultRoomOptions returns the options used for a zero RoomOptions.
+func DefaultRoomOptions() RoomOptions {
+	return RoomOptions{MaxRooms: 12, MinRoomSize: 4, MaxRoomSize: 10, Attempts: 20}
+}
+
+// RoomsAndCorridors generates the classic dungeon of rectangular rooms
+// placed at random without overlapping, each connected to the previous one
+// with an L-shaped corridor. It returns the grid and the rooms.
+func RoomsAndCorridors(random *rand.Rand, size geometry.Point, options RoomOptions) (*Grid, []geometry.Rect) {
+	if options == (RoomOptions{}) {
+		options = DefaultRoomOptions()
+	}
+	g := NewGrid(size, Wall)
+	var rooms []geometry.Rect
+	for len(rooms) < options.MaxRooms {
+		room, ok := findRoomSpot(random, g, rooms, options)
+		if !ok {
+			break
+		}
+		g.Fill(room, Floor)
+		if len(rooms) > 0 {
+			carveCorridor(random, g, rooms[len(rooms)-1].Center(), room.Center())
+		}
+		rooms = append(rooms, room)
+	}
+	Connect(random, g)
+	return g, rooms
+}
+
+func findRoomSpot(random *rand.Rand, g *Grid, rooms []geometry.Rect, options RoomOptions) (geometry.Rect, bool) {
+	interior := g.interior()
+	area := interior.Size()
+	for attempt := 0; attempt < max(options.Attempts, 1); attempt++ {
+		w := options.MinRoomSize + random.Intn(max(options.MaxRoomSize-options.MinRoomSize, 0)+1)
+		h := options.MinRoomSize + random.Intn(max(options.MaxRoomSize-options.MinRoomSize, 0)+1)
+		if w > area.X || h > area.Y {
+			continue
+		}
+		x := interior.Min.X + random.Intn(area.X-w+1)
+		y := interior.Min.Y + random.Intn(area.Y-h+1)
+		room := geometry.NewRect(x, y, x+w, y+h)
+		if !overlapsAny(room, rooms, 1) {
+			return room, true
+		}
+	}
+	return geometry.Rect{}, false
+}
+
+// overlapsAny reports whether a range, grown by margin on each side,
+// overlaps one of the given ranges.
+func overlapsAny(rg geometry.Rect, others []geometry.Rect, margin int) bool {
+	grown := geometry.NewRect(rg.Min.X-margin, rg.Min.Y-margin, rg.Max.X+margin, rg.Max.Y+margin)
+	for _, other := range others {
+		if grown.Overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prefab is a hand-made room. Its layout uses the runes of Cell.Rune, and a
+// space for cells that are left as they are.
+type Prefab struct {
+	Name   string
+	Layout []string
+}
+
+// NewPrefab returns a prefab from a layout given as lines of text.
+func NewPrefab(name, layout string) Prefab {
+	return Prefab{Name: name, Layout: strings.Split(strings.Trim(layout, "\n"), "\n")}
+}
+
+// Size returns the size of the prefab's layout.
+func (p Prefab) Size() geometry.Point {
+	size := geometry.Point{Y: len(p.Layout)}
+	for _, line := range p.Layout {
+		size.X = max(size.X, len([]rune(line)))
+	}
+	return size
+}
+
+// Stamp writes the prefab to the grid with its top left corner at a
+// position.
+func (p Prefab) Stamp(g *Grid, at geometry.Point) {
+	for y, line := range p.Layout {
+		for x, r := range []rune(line) {
+			if r != ' ' {
+				g.Set(at.Add(geometry.Point{X: x, Y: y}), CellFromRune(r))
+			}
+		}
+	}
+}
+
+// PlacedPrefab is a prefab placed in a grid.
+type PlacedPrefab struct {
+	Prefab Prefab
+	Bounds geometry.Rect
+}
+
+// PlacePrefabs stamps up to count randomly chosen prefabs at random
+// positions of the grid where they overlap no other placed prefab and no
+// passable cell, then connects the grid. It returns the placed prefabs.
+func PlacePrefabs(random *rand.Rand, g *Grid, prefabs []Prefab, count int) []PlacedPrefab {
+	if len(prefabs) == 0 {
+		return nil
+	}
+	interior := g.interior()
+	var placed []PlacedPrefab
+	var bounds []geometry.Rect
+	for i := 0; i < count; i++ {
+		prefab := prefabs[random.Intn(len(prefabs))]
+		size := prefab.Size()
+		area := interior.Size()
+		if size.X > area.X || size.Y > area.Y {
+			continue
+		}
+		for attempt := 0; attempt < 20; attempt++ {
+			x := interior.Min.X + random.Intn(area.X-size.X+1)
+			y := interior.Min.Y + random.Intn(area.Y-size.Y+1)
+			rg := geometry.NewRect(x, y, x+size.X, y+size.Y)
+			if overlapsAny(rg, bounds, 1) || hasPassable(g, rg) {
+				continue
+			}
+			prefab.Stamp(g, rg.Min)
+			placed = append(placed, PlacedPrefab{Prefab: prefab, Bounds: rg})
+			bounds = append(bounds, rg)
+			break
+		}
+	}
+	Connect(random, g)
+	return placed
+}
+
+func hasPassable(g *Grid, rg geometry.Rect) bool {
+	found := false
+	rg.Iter(func(p geometry.Point) {
+		found = found || g.IsPassable(p)
+	})
+	return found
+}
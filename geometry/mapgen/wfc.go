@@ -0,0 +1,374 @@
+package mapgen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/memmaker/go/geometry"
+)
+
+// ErrWFCContradiction is returned by WFC.Generate when no grid satisfying
+// the rules was found within the allowed number of backtracks.
+var ErrWFCContradiction = errors.New("wfc: contradiction, no solution found")
+
+// wfcDirections are the offsets of the four neighbors used for adjacency.
+var wfcDirections = [4]geometry.Point{
+	geometry.RelativeNorth, geometry.RelativeEast, geometry.RelativeSouth, geometry.RelativeWest,
+}
+
+// WFC is a Wave Function Collapse model over integer tile IDs, such as the
+// tile indices stored by textiles.SaveTileMap16. The model is learned from a
+// sample map, either as single tiles with their observed neighbors (simple
+// tiled model) or as the N x N patterns occurring in the sample
+// (overlapping model).
+type WFC struct {
+	n          int       // pattern size, 1 for the simple tiled model
+	patterns   [][]int16 // n*n tiles per pattern, row by row
+	weights    []float64
+	compatible [4][]bool // [direction][p*len(patterns)+q]: q may be next to p
+}
+
+// NewSimpleTiledWFC learns a simple tiled model from a sample map: two tiles
+// may be neighbors in a direction if they are neighbors in that direction
+// somewhere in the sample. Tiles are weighted by their frequency in the
+// sample.
+func NewSimpleTiledWFC(sample []int16, sampleSize geometry.Point) *WFC {
+	frequencies := map[int16]int{}
+	for _, tile := range sample {
+		frequencies[tile]++
+	}
+	tiles := make([]int16, 0, len(frequencies))
+	for tile := range frequencies {
+		tiles = append(tiles, tile)
+	}
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i] < tiles[j] })
+	index := make(map[int16]int, len(tiles))
+	w := &WFC{n: 1}
+	for i, tile := range tiles {
+		index[tile] = i
+		w.patterns = append(w.patterns, []int16{tile})
+		w.weights = append(w.weights, float64(frequencies[tile]))
+	}
+	count := len(tiles)
+	for d := range wfcDirections {
+		w.compatible[d] = make([]bool, count*count)
+	}
+	rg := geometry.NewRect(0, 0, sampleSize.X, sampleSize.Y)
+	rg.Iter(func(p geometry.Point) {
+		a := index[sample[p.Y*sampleSize.X+p.X]]
+		for d, dir := range wfcDirections {
+			q := p.Add(dir)
+			if q.In(rg) {
+				b := index[sample[q.Y*sampleSize.X+q.X]]
+				w.compatible[d][a*count+b] = true
+			}
+		}
+	})
+	return w
+}
+
+// NewOverlappingWFC learns an overlapping model from a sample map, using all
+// n x n patterns of the sample. With periodic, patterns wrap around the
+// edges of the sample. Patterns are weighted by their frequency in the
+// sample, and two patterns may be neighbors if they agree where they
+// overlap.
+func NewOverlappingWFC(sample []int16, sampleSize geometry.Point, n int, periodic bool) *WFC {
+	w := &WFC{n: max(n, 1)}
+	n = w.n
+	maxX, maxY := sampleSize.X-n, sampleSize.Y-n
+	if periodic {
+		maxX, maxY = sampleSize.X-1, sampleSize.Y-1
+	}
+	index := map[string]int{}
+	for y := 0; y <= maxY; y++ {
+		for x := 0; x <= maxX; x++ {
+			pattern := make([]int16, n*n)
+			for dy := 0; dy < n; dy++ {
+				for dx := 0; dx < n; dx++ {
+					sx, sy := (x+dx)%sampleSize.X, (y+dy)%sampleSize.Y
+					pattern[dy*n+dx] = sample[sy*sampleSize.X+sx]
+				}
+			}
+			key := fmt.Sprint(pattern)
+			if i, ok := index[key]; ok {
+				w.weights[i]++
+				continue
+			}
+			index[key] = len(w.patterns)
+			w.patterns = append(w.patterns, pattern)
+			w.weights = append(w.weights, 1)
+		}
+	}
+	count := len(w.patterns)
+	for d, dir := range wfcDirections {
+		w.compatible[d] = make([]bool, count*count)
+		for p := range w.patterns {
+			for q := range w.patterns {
+				w.compatible[d][p*count+q] = w.agrees(w.patterns[p], w.patterns[q], dir)
+			}
+		}
+	}
+	return w
+}
+
+// agrees reports whether pattern q, placed at offset dir from pattern p,
+// has the same tiles as p where they overlap.
+func (w *WFC) agrees(p, q []int16, dir geometry.Point) bool {
+	n := w.n
+	for y := max(0, dir.Y); y < min(n, n+dir.Y); y++ {
+		for x := max(0, dir.X); x < min(n, n+dir.X); x++ {
+			if p[y*n+x] != q[(y-dir.Y)*n+x-dir.X] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PatternCount returns the number of distinct patterns, or tiles for the
+// simple tiled model.
+func (w *WFC) PatternCount() int {
+	return len(w.patterns)
+}
+
+// SetTileWeight sets the weight of a tile, replacing the frequency learned
+// from the sample. For the overlapping model, it sets the weight of all
+// patterns with that tile in their top left corner. A weight of 0 prevents
+// the tile from being chosen, unless required by a fixed cell.
+func (w *WFC) SetTileWeight(tile int16, weight float64) {
+	for i, pattern := range w.patterns {
+		if pattern[0] == tile {
+			w.weights[i] = weight
+		}
+	}
+}
+
+// wfcState is the wave: the patterns still possible at each position. Bans
+// are recorded in a trail, so that they can be undone when backtracking.
+type wfcState struct {
+	possible []bool
+	count    []int
+	trail    []int
+}
+
+// undo restores the bans recorded after the given trail length.
+func (s *wfcState) undo(mark int, patterns int) {
+	for i := len(s.trail) - 1; i >= mark; i-- {
+		s.possible[s.trail[i]] = true
+		s.count[s.trail[i]/patterns]++
+	}
+	s.trail = s.trail[:mark]
+}
+
+// wfcChoice is a decision that can be undone by backtracking.
+type wfcChoice struct {
+	mark    int // trail length before the decision
+	cell    int
+	pattern int
+}
+
+// Generate returns a grid of tile IDs of the given size, row by row, in
+// which every pair of neighbors follows the rules of the model. The fixed
+// cells are pre-placed before generating. When the generator runs into a
+// contradiction, it undoes its last decisions, up to maxBacktracks times in
+// total, before giving up with ErrWFCContradiction. The result only depends
+// on the state of random.
+func (w *WFC) Generate(random *rand.Rand, size geometry.Point, fixed map[geometry.Point]int16, maxBacktracks int) ([]int16, error) {
+	n, count := w.n, len(w.patterns)
+	wave := geometry.Point{X: size.X - n + 1, Y: size.Y - n + 1}
+	if count == 0 || wave.X < 1 || wave.Y < 1 {
+		return nil, ErrWFCContradiction
+	}
+	cells := wave.X * wave.Y
+	state := &wfcState{possible: make([]bool, cells*count), count: make([]int, cells)}
+	for i := range state.possible {
+		state.possible[i] = true
+	}
+	for i := range state.count {
+		state.count[i] = count
+	}
+
+	// Pre-place the fixed cells, visiting them in a deterministic order.
+	fixedPositions := make([]geometry.Point, 0, len(fixed))
+	for p := range fixed {
+		fixedPositions = append(fixedPositions, p)
+	}
+	sort.Slice(fixedPositions, func(i, j int) bool {
+		a, b := fixedPositions[i], fixedPositions[j]
+		return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+	})
+	var changed []int
+	for _, p := range fixedPositions {
+		tile := fixed[p]
+		for dy := 0; dy < n; dy++ {
+			for dx := 0; dx < n; dx++ {
+				wp := p.Sub(geometry.Point{X: dx, Y: dy})
+				if wp.X < 0 || wp.Y < 0 || wp.X >= wave.X || wp.Y >= wave.Y {
+					continue
+				}
+				cell := wp.Y*wave.X + wp.X
+				for pattern := 0; pattern < count; pattern++ {
+					if state.possible[cell*count+pattern] && w.patterns[pattern][dy*n+dx] != tile {
+						w.ban(state, cell, pattern)
+					}
+				}
+				changed = append(changed, cell)
+			}
+		}
+	}
+	if !w.propagate(state, wave, changed) {
+		return nil, ErrWFCContradiction
+	}
+
+	var choices []wfcChoice
+	backtracks := 0
+	for {
+		cell := w.lowestEntropyCell(random, state)
+		if cell < 0 {
+			break
+		}
+		pattern := w.choosePattern(random, state, cell)
+		choices = append(choices, wfcChoice{mark: len(state.trail), cell: cell, pattern: pattern})
+		for q := 0; q < count; q++ {
+			if q != pattern && state.possible[cell*count+q] {
+				w.ban(state, cell, q)
+			}
+		}
+		ok := w.propagate(state, wave, []int{cell})
+		for !ok {
+			if len(choices) == 0 || backtracks >= maxBacktracks {
+				return nil, ErrWFCContradiction
+			}
+			backtracks++
+			last := choices[len(choices)-1]
+			choices = choices[:len(choices)-1]
+			state.undo(last.mark, count)
+			w.ban(state, last.cell, last.pattern)
+			ok = state.count[last.cell] > 0 && w.propagate(state, wave, []int{last.cell})
+		}
+	}
+
+	tiles := make([]int16, size.X*size.Y)
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			wx, wy := min(x, wave.X-1), min(y, wave.Y-1)
+			cell := wy*wave.X + wx
+			for pattern := 0; pattern < count; pattern++ {
+				if state.possible[cell*count+pattern] {
+					tiles[y*size.X+x] = w.patterns[pattern][(y-wy)*n+x-wx]
+					break
+				}
+			}
+		}
+	}
+	return tiles, nil
+}
+
+func (w *WFC) ban(state *wfcState, cell, pattern int) {
+	i := cell*len(w.patterns) + pattern
+	state.possible[i] = false
+	state.count[cell]--
+	state.trail = append(state.trail, i)
+}
+
+// propagate removes the patterns that have no compatible pattern left in a
+// neighbor, starting from the given cells. It returns false on a
+// contradiction.
+func (w *WFC) propagate(state *wfcState, wave geometry.Point, queue []int) bool {
+	count := len(w.patterns)
+	for len(queue) > 0 {
+		cell := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if state.count[cell] == 0 {
+			return false
+		}
+		p := geometry.Point{X: cell % wave.X, Y: cell / wave.X}
+		for d, dir := range wfcDirections {
+			q := p.Add(dir)
+			if q.X < 0 || q.Y < 0 || q.X >= wave.X || q.Y >= wave.Y {
+				continue
+			}
+			neighbor := q.Y*wave.X + q.X
+			changed := false
+			for b := 0; b < count; b++ {
+				if !state.possible[neighbor*count+b] {
+					continue
+				}
+				supported := false
+				for a := 0; a < count; a++ {
+					if state.possible[cell*count+a] && w.compatible[d][a*count+b] {
+						supported = true
+						break
+					}
+				}
+				if !supported {
+					w.ban(state, neighbor, b)
+					changed = true
+				}
+			}
+			if state.count[neighbor] == 0 {
+				return false
+			}
+			if changed {
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return true
+}
+
+// lowestEntropyCell returns the undecided cell with the lowest entropy, or
+// -1 if all cells are decided.
+func (w *WFC) lowestEntropyCell(random *rand.Rand, state *wfcState) int {
+	count := len(w.patterns)
+	best, bestEntropy := -1, math.Inf(1)
+	for cell, possible := range state.count {
+		if possible <= 1 {
+			continue
+		}
+		sum, sumLog := 0.0, 0.0
+		for pattern := 0; pattern < count; pattern++ {
+			if weight := w.weights[pattern]; state.possible[cell*count+pattern] && weight > 0 {
+				sum += weight
+				sumLog += weight * math.Log(weight)
+			}
+		}
+		entropy := 0.0
+		if sum > 0 {
+			entropy = math.Log(sum) - sumLog/sum
+		}
+		// A little noise breaks ties without favoring the first cells.
+		entropy += random.Float64() * 1e-6
+		if entropy < bestEntropy {
+			best, bestEntropy = cell, entropy
+		}
+	}
+	return best
+}
+
+// choosePattern picks one of the possible patterns of a cell by weight.
+func (w *WFC) choosePattern(random *rand.Rand, state *wfcState, cell int) int {
+	count := len(w.patterns)
+	sum := 0.0
+	var candidates []int
+	for pattern := 0; pattern < count; pattern++ {
+		if state.possible[cell*count+pattern] {
+			candidates = append(candidates, pattern)
+			sum += w.weights[pattern]
+		}
+	}
+	if sum <= 0 {
+		return candidates[random.Intn(len(candidates))]
+	}
+	r := random.Float64() * sum
+	for _, pattern := range candidates {
+		r -= w.weights[pattern]
+		if r < 0 && w.weights[pattern] > 0 {
+			return pattern
+		}
+	}
+	return candidates[len(candidates)-1]
+}
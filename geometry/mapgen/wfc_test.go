@@ -0,0 +1,124 @@
+package mapgen
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/memmaker/go/geometry"
+)
+
+// testWFCSample is a small map of rooms: walls (1) surround floors (0), with
+// doors (2) between floors.
+var testWFCSample = []int16{
+	1, 1, 1, 1, 1, 1, 1, 1,
+	1, 0, 0, 1, 0, 0, 0, 1,
+	1, 0, 0, 2, 0, 0, 0, 1,
+	1, 0, 0, 1, 0, 0, 0, 1,
+	1, 1, 2, 1, 1, 2, 1, 1,
+	1, 0, 0, 0, 1, 0, 0, 1,
+	1, 0, 0, 0, 2, 0, 0, 1,
+	1, 1, 1, 1, 1, 1, 1, 1,
+}
+
+var testWFCSampleSize = geometry.Point{X: 8, Y: 8}
+
+// windows returns the n x n windows of a grid.
+func windows(tiles []int16, size geometry.Point, n int) map[string]bool {
+	set := map[string]bool{}
+	for y := 0; y+n <= size.Y; y++ {
+		for x := 0; x+n <= size.X; x++ {
+			window := make([]int16, 0, n*n)
+			for dy := 0; dy < n; dy++ {
+				window = append(window, tiles[(y+dy)*size.X+x:(y+dy)*size.X+x+n]...)
+			}
+			set[fmt.Sprint(window)] = true
+		}
+	}
+	return set
+}
+
+func TestSimpleTiledWFC(t *testing.T) {
+	w := NewSimpleTiledWFC(testWFCSample, testWFCSampleSize)
+	if w.PatternCount() != 3 {
+		t.Fatalf("got %d tiles, want 3", w.PatternCount())
+	}
+	size := geometry.Point{X: 20, Y: 15}
+	fixed := map[geometry.Point]int16{{X: 5, Y: 5}: 2, {X: 0, Y: 0}: 0}
+	tiles, err := w.Generate(rand.New(rand.NewSource(1)), size, fixed, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, tile := range fixed {
+		if tiles[p.Y*size.X+p.X] != tile {
+			t.Errorf("fixed tile at %v: got %d, want %d", p, tiles[p.Y*size.X+p.X], tile)
+		}
+	}
+	// Every pair of neighbors occurs in the sample.
+	pairs := map[[3]int]bool{}
+	collect := func(grid []int16, size geometry.Point, add bool) {
+		geometry.NewRect(0, 0, size.X, size.Y).Iter(func(p geometry.Point) {
+			for d, dir := range wfcDirections {
+				q := p.Add(dir)
+				if q.X < 0 || q.Y < 0 || q.X >= size.X || q.Y >= size.Y {
+					continue
+				}
+				pair := [3]int{d, int(grid[p.Y*size.X+p.X]), int(grid[q.Y*size.X+q.X])}
+				if add {
+					pairs[pair] = true
+				} else if !pairs[pair] {
+					t.Fatalf("tiles %v at %v not in the sample", pair, p)
+				}
+			}
+		})
+	}
+	collect(testWFCSample, testWFCSampleSize, true)
+	collect(tiles, size, false)
+
+	again, _ := w.Generate(rand.New(rand.NewSource(1)), size, fixed, 100)
+	if !reflect.DeepEqual(tiles, again) {
+		t.Errorf("same seed gave different grids")
+	}
+}
+
+func TestOverlappingWFC(t *testing.T) {
+	for _, n := range []int{2, 3} {
+		w := NewOverlappingWFC(testWFCSample, testWFCSampleSize, n, false)
+		size := geometry.Point{X: 16, Y: 12}
+		tiles, err := w.Generate(rand.New(rand.NewSource(2)), size, nil, 1000)
+		if err != nil {
+			t.Fatalf("n = %d: %v", n, err)
+		}
+		sample := windows(testWFCSample, testWFCSampleSize, n)
+		for window := range windows(tiles, size, n) {
+			if !sample[window] {
+				t.Fatalf("n = %d: pattern %s not in the sample", n, window)
+			}
+		}
+	}
+}
+
+func TestWFCTileWeight(t *testing.T) {
+	w := NewSimpleTiledWFC(testWFCSample, testWFCSampleSize)
+	w.SetTileWeight(2, 0)
+	size := geometry.Point{X: 12, Y: 12}
+	tiles, err := w.Generate(rand.New(rand.NewSource(3)), size, nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tile := range tiles {
+		if tile == 2 {
+			t.Fatalf("tile of weight 0 chosen at %d", i)
+		}
+	}
+}
+
+func TestWFCContradiction(t *testing.T) {
+	w := NewSimpleTiledWFC(testWFCSample, testWFCSampleSize)
+	// Doors are never next to each other in the sample.
+	fixed := map[geometry.Point]int16{{X: 2, Y: 2}: 2, {X: 3, Y: 2}: 2}
+	if _, err := w.Generate(rand.New(rand.NewSource(4)), geometry.Point{X: 6, Y: 6}, fixed, 10); err != ErrWFCContradiction {
+		t.Errorf("got error %v, want ErrWFCContradiction", err)
+	}
+}
@@ -35,6 +35,7 @@ type PathRange struct {
 type pathRange struct {
 	diags               bool             // JPS diagonal movement
 	passable            func(Point) bool // JPS passable function
+	coop                *coopNodes       // cooperative A* nodes
 	AstarNodes          *nodeMap
 	DijkstraNodes       *nodeMap // dijkstra map
 	DijkstraIterNodes   []Node
@@ -91,6 +92,7 @@ func (pr *PathRange) SetRange(rg Rect) {
 	pr.Rg = rg
 	max := rg.Size()
 	if max.X*max.Y <= pr.Capacity {
+		pr.W = max.X
 		return
 	}
 	npr := NewPathRange(rg)
@@ -0,0 +1,94 @@
+package geometry
+
+import "sync"
+
+// PathRangePool is a pool of PathRange values for the same range. A single
+// PathRange caches mutable structures and cannot be used from several
+// goroutines at once, but the pool can: each query borrows a PathRange for
+// its duration, so parallel AI code can share one pool.
+//
+// The query methods return results that do not share memory with the
+// pooled PathRange caches, so they stay valid after the call. The Pather,
+// Dijkstra and Astar values passed to them must be safe for concurrent use
+// themselves; note that a shared Neighbors value is not.
+type PathRangePool struct {
+	rg   Rect
+	pool sync.Pool
+}
+
+// NewPathRangePool returns a new pool of PathRange values for the given
+// range. The PathRange values are created on demand.
+func NewPathRangePool(rg Rect) *PathRangePool {
+	p := &PathRangePool{rg: rg}
+	p.pool.New = func() any {
+		return NewPathRange(rg)
+	}
+	return p
+}
+
+// Range returns the range of the pooled PathRange values.
+func (p *PathRangePool) Range() Rect {
+	return p.rg
+}
+
+// Get borrows a PathRange from the pool. It must not be used after it has
+// been returned with Put.
+func (p *PathRangePool) Get() *PathRange {
+	return p.pool.Get().(*PathRange)
+}
+
+// Put returns a PathRange borrowed with Get to the pool.
+func (p *PathRangePool) Put(pr *PathRange) {
+	p.pool.Put(pr)
+}
+
+// With calls fn with a borrowed PathRange, which is returned to the pool
+// afterwards. This is useful for several queries that use the caches of the
+// same PathRange, such as DijkstraMap followed by DijkstraMapAt.
+func (p *PathRangePool) With(fn func(pr *PathRange)) {
+	pr := p.Get()
+	defer p.Put(pr)
+	fn(pr)
+}
+
+// AstarPath is like PathRange.AstarPath.
+func (p *PathRangePool) AstarPath(ast Astar, from, to Point) []Point {
+	pr := p.Get()
+	defer p.Put(pr)
+	return pr.AstarPath(ast, from, to)
+}
+
+// JPSPath is like PathRange.JPSPath. The path slice is used as in
+// PathRange.JPSPath, so it must not be shared between goroutines either.
+func (p *PathRangePool) JPSPath(path []Point, from, to Point, passable func(Point) bool, diags bool) []Point {
+	pr := p.Get()
+	defer p.Put(pr)
+	return pr.JPSPath(path, from, to, passable, diags)
+}
+
+// DijkstraMap is like PathRange.DijkstraMap, but returns a new slice.
+func (p *PathRangePool) DijkstraMap(dij Dijkstra, sources []Point, maxCost int) []Node {
+	pr := p.Get()
+	defer p.Put(pr)
+	nodes := pr.DijkstraMap(dij, sources, maxCost)
+	return append([]Node(nil), nodes...)
+}
+
+// BreadthFirstMap is like PathRange.BreadthFirstMap, but returns a new slice.
+func (p *PathRangePool) BreadthFirstMap(nb Pather, sources []Point, maxCost int) []Node {
+	pr := p.Get()
+	defer p.Put(pr)
+	nodes := pr.BreadthFirstMap(nb, sources, maxCost)
+	return append([]Node(nil), nodes...)
+}
+
+// CCMap is like PathRange.CCMap, but returns a new slice.
+func (p *PathRangePool) CCMap(nb Pather, pos Point) []Point {
+	pr := p.Get()
+	defer p.Put(pr)
+	ps := pr.CCMap(nb, pos)
+	if ps == nil {
+		return nil
+	}
+	return append([]Point(nil), ps...)
+}
@@ -0,0 +1,221 @@
+package geometry
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const (
+	testPoolWidth      = 60
+	testPoolHeight     = 40
+	testPoolGoroutines = 16
+	testPoolQueryCount = 25
+)
+
+// testPoolMap is a read-only map with random walls, safe for concurrent
+// use because it allocates a new slice for every Neighbors call.
+type testPoolMap struct {
+	walls map[Point]bool
+}
+
+func newTestPoolMap() *testPoolMap {
+	random := rand.New(rand.NewSource(1))
+	m := &testPoolMap{walls: make(map[Point]bool)}
+	for i := 0; i < testPoolWidth*testPoolHeight/4; i++ {
+		m.walls[Point{X: random.Intn(testPoolWidth), Y: random.Intn(testPoolHeight)}] = true
+	}
+	return m
+}
+
+func (m *testPoolMap) passable(p Point) bool {
+	return p.In(NewRect(0, 0, testPoolWidth, testPoolHeight)) && !m.walls[p]
+}
+
+func (m *testPoolMap) Neighbors(p Point) []Point {
+	if !m.passable(p) {
+		return nil
+	}
+	nb := &Neighbors{}
+	return append([]Point(nil), nb.All(p, m.passable)...)
+}
+
+func (m *testPoolMap) Cost(p, q Point) int {
+	return 1 + q.X%3
+}
+
+func (m *testPoolMap) Estimation(p, q Point) int {
+	return DistanceChebyshev(p, q)
+}
+
+type testPoolQuery struct {
+	from, to Point
+}
+
+func testPoolQueries() []testPoolQuery {
+	random := rand.New(rand.NewSource(2))
+	queries := make([]testPoolQuery, testPoolQueryCount)
+	for i := range queries {
+		queries[i] = testPoolQuery{
+			from: Point{X: random.Intn(testPoolWidth), Y: random.Intn(testPoolHeight)},
+			to:   Point{X: random.Intn(testPoolWidth), Y: random.Intn(testPoolHeight)},
+		}
+	}
+	return queries
+}
+
+// runConcurrently calls fn for every query from several goroutines at once.
+func runConcurrently(queries []testPoolQuery, fn func(i int, q testPoolQuery)) {
+	var wg sync.WaitGroup
+	for g := 0; g < testPoolGoroutines; g++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for i := range queries {
+				j := (i + offset) % len(queries)
+				fn(j, queries[j])
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestPathRangePoolAstarPath(t *testing.T) {
+	t.Parallel()
+
+	m := newTestPoolMap()
+	queries := testPoolQueries()
+	rg := NewRect(0, 0, testPoolWidth, testPoolHeight)
+
+	expected := make([][]Point, len(queries))
+	pr := NewPathRange(rg)
+	for i, q := range queries {
+		expected[i] = pr.AstarPath(m, q.from, q.to)
+	}
+
+	pool := NewPathRangePool(rg)
+	runConcurrently(queries, func(i int, q testPoolQuery) {
+		path := pool.AstarPath(m, q.from, q.to)
+		if !reflect.DeepEqual(path, expected[i]) {
+			t.Errorf("AstarPath %v -> %v: expected %v, got %v", q.from, q.to, expected[i], path)
+		}
+	})
+}
+
+func TestPathRangePoolJPSPath(t *testing.T) {
+	t.Parallel()
+
+	m := newTestPoolMap()
+	queries := testPoolQueries()
+	rg := NewRect(0, 0, testPoolWidth, testPoolHeight)
+
+	expected := make([][]Point, len(queries))
+	pr := NewPathRange(rg)
+	for i, q := range queries {
+		expected[i] = pr.JPSPath(nil, q.from, q.to, m.passable, true)
+	}
+
+	pool := NewPathRangePool(rg)
+	runConcurrently(queries, func(i int, q testPoolQuery) {
+		path := pool.JPSPath(nil, q.from, q.to, m.passable, true)
+		if len(path) != len(expected[i]) {
+			t.Errorf("JPSPath %v -> %v: expected length %d, got %d", q.from, q.to, len(expected[i]), len(path))
+		}
+	})
+}
+
+func TestPathRangePoolDijkstraMap(t *testing.T) {
+	t.Parallel()
+
+	m := newTestPoolMap()
+	queries := testPoolQueries()
+	rg := NewRect(0, 0, testPoolWidth, testPoolHeight)
+
+	expected := make([]map[Point]int, len(queries))
+	pr := NewPathRange(rg)
+	for i, q := range queries {
+		expected[i] = nodeCosts(pr.DijkstraMap(m, []Point{q.from}, 50))
+	}
+
+	pool := NewPathRangePool(rg)
+	runConcurrently(queries, func(i int, q testPoolQuery) {
+		costs := nodeCosts(pool.DijkstraMap(m, []Point{q.from}, 50))
+		if !reflect.DeepEqual(costs, expected[i]) {
+			t.Errorf("DijkstraMap from %v: got %d nodes, expected %d", q.from, len(costs), len(expected[i]))
+		}
+	})
+}
+
+func TestPathRangePoolBreadthFirstMap(t *testing.T) {
+	t.Parallel()
+
+	m := newTestPoolMap()
+	queries := testPoolQueries()
+	rg := NewRect(0, 0, testPoolWidth, testPoolHeight)
+
+	expected := make([]map[Point]int, len(queries))
+	pr := NewPathRange(rg)
+	for i, q := range queries {
+		expected[i] = nodeCosts(pr.BreadthFirstMap(m, []Point{q.from}, 30))
+	}
+
+	pool := NewPathRangePool(rg)
+	runConcurrently(queries, func(i int, q testPoolQuery) {
+		costs := nodeCosts(pool.BreadthFirstMap(m, []Point{q.from}, 30))
+		if !reflect.DeepEqual(costs, expected[i]) {
+			t.Errorf("BreadthFirstMap from %v: got %d nodes, expected %d", q.from, len(costs), len(expected[i]))
+		}
+	})
+}
+
+func TestPathRangePoolCCMap(t *testing.T) {
+	t.Parallel()
+
+	m := newTestPoolMap()
+	queries := testPoolQueries()
+	rg := NewRect(0, 0, testPoolWidth, testPoolHeight)
+
+	expected := make([]int, len(queries))
+	pr := NewPathRange(rg)
+	for i, q := range queries {
+		expected[i] = len(pr.CCMap(m, q.from))
+	}
+
+	pool := NewPathRangePool(rg)
+	runConcurrently(queries, func(i int, q testPoolQuery) {
+		component := pool.CCMap(m, q.from)
+		if len(component) != expected[i] {
+			t.Errorf("CCMap at %v: expected %d positions, got %d", q.from, expected[i], len(component))
+		}
+	})
+}
+
+func TestPathRangePoolWith(t *testing.T) {
+	t.Parallel()
+
+	m := newTestPoolMap()
+	queries := testPoolQueries()
+	rg := NewRect(0, 0, testPoolWidth, testPoolHeight)
+	pool := NewPathRangePool(rg)
+
+	runConcurrently(queries, func(i int, q testPoolQuery) {
+		pool.With(func(pr *PathRange) {
+			nodes := pr.DijkstraMap(m, []Point{q.from}, 50)
+			for _, n := range nodes {
+				if cost := pr.DijkstraMapAt(n.P); cost != n.Cost {
+					t.Errorf("DijkstraMapAt %v: expected %d, got %d", n.P, n.Cost, cost)
+					return
+				}
+			}
+		})
+	})
+}
+
+func nodeCosts(nodes []Node) map[Point]int {
+	costs := make(map[Point]int, len(nodes))
+	for _, n := range nodes {
+		costs[n.P] = n.Cost
+	}
+	return costs
+}
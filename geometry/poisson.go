@@ -0,0 +1,114 @@
+package geometry
+
+import (
+	"math"
+	"math/rand"
+)
+
+// poissonAttempts is the number of candidates tried around each active
+// sample before it is retired, as suggested by Bridson.
+const poissonAttempts = 30
+
+// PoissonDiscSample returns well-spaced random positions within a range,
+// using Bridson's algorithm: no two positions are closer than minDistance
+// (in Euclidean distance), and every passable position is closer than
+// minDistance to one of them, so that the positions look evenly spread
+// without the regularity of a grid. Only positions for which passable
+// returns true are sampled; a nil passable allows all positions.
+//
+// Areas of passable positions which are not connected to others, such as
+// islands, are seeded separately: first from random positions, then from
+// every position left far enough from all samples, so that they are
+// sampled too.
+func PoissonDiscSample(random *rand.Rand, rg Rect, minDistance float64, passable func(Point) bool) []Point {
+	if rg.Empty() || minDistance <= 0 {
+		return nil
+	}
+	if passable == nil {
+		passable = func(Point) bool { return true }
+	}
+	// The background grid has cells small enough to hold at most one
+	// sample, so that checking the samples near a candidate is cheap.
+	cellSize := minDistance / math.Sqrt2
+	size := rg.Size()
+	columns := int(math.Ceil(float64(size.X)/cellSize)) + 1
+	rows := int(math.Ceil(float64(size.Y)/cellSize)) + 1
+	grid := make([]int, columns*rows)
+	for i := range grid {
+		grid[i] = -1
+	}
+	cellOf := func(p Point) (int, int) {
+		p = p.Sub(rg.Min)
+		return int(float64(p.X) / cellSize), int(float64(p.Y) / cellSize)
+	}
+	minSquared := minDistance * minDistance
+	var samples []Point
+	isFarEnough := func(p Point) bool {
+		cx, cy := cellOf(p)
+		for y := cy - 2; y <= cy+2; y++ {
+			for x := cx - 2; x <= cx+2; x++ {
+				if x < 0 || y < 0 || x >= columns || y >= rows {
+					continue
+				}
+				if i := grid[y*columns+x]; i >= 0 && float64(DistanceSquared(p, samples[i])) < minSquared {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	var active []int
+	add := func(p Point) {
+		cx, cy := cellOf(p)
+		grid[cy*columns+cx] = len(samples)
+		active = append(active, len(samples))
+		samples = append(samples, p)
+	}
+
+	grow := func() {
+		for len(active) > 0 {
+			ai := random.Intn(len(active))
+			center := samples[active[ai]]
+			found := false
+			for attempt := 0; attempt < poissonAttempts; attempt++ {
+				angle := random.Float64() * 2 * math.Pi
+				radius := minDistance * (1 + random.Float64())
+				p := Point{
+					X: center.X + int(math.Round(radius*math.Cos(angle))),
+					Y: center.Y + int(math.Round(radius*math.Sin(angle))),
+				}
+				if p.In(rg) && passable(p) && isFarEnough(p) {
+					add(p)
+					found = true
+					break
+				}
+			}
+			if !found {
+				active[ai] = active[len(active)-1]
+				active = active[:len(active)-1]
+			}
+		}
+	}
+
+	failures := 0
+	for failures < poissonAttempts {
+		// Seed a new area with a random position.
+		seed := rg.GetRandomPoint(random)
+		if !passable(seed) || !isFarEnough(seed) {
+			failures++
+			continue
+		}
+		failures = 0
+		add(seed)
+		grow()
+	}
+	// Random seeds miss small islands and gaps, so that the remaining ones
+	// are seeded in order.
+	rg.Iter(func(p Point) {
+		if passable(p) && isFarEnough(p) {
+			add(p)
+			grow()
+		}
+	})
+	return samples
+}
@@ -0,0 +1,227 @@
+package geometry
+
+import "sort"
+
+// SpatialHash is a spatial index of items, such as entities, at grid
+// positions. The positions are bucketed in square cells, so that the items
+// near a position or in a range, such as a Camera's viewport, can be found
+// without scanning all items. Each item has a single position.
+type SpatialHash[T comparable] struct {
+	cellSize  int
+	cells     map[Point][]T
+	positions map[T]Point
+}
+
+// NewSpatialHash returns an empty spatial hash with cells of the given size.
+// A good cell size is about the radius of typical queries.
+func NewSpatialHash[T comparable](cellSize int) *SpatialHash[T] {
+	if cellSize < 1 {
+		cellSize = 1
+	}
+	return &SpatialHash[T]{
+		cellSize:  cellSize,
+		cells:     make(map[Point][]T),
+		positions: make(map[T]Point),
+	}
+}
+
+// cell returns the cell of a position, rounding towards negative infinity
+// so that negative coordinates are bucketed correctly.
+func (h *SpatialHash[T]) cell(p Point) Point {
+	return Point{X: floorDiv(p.X, h.cellSize), Y: floorDiv(p.Y, h.cellSize)}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// Len returns the number of items.
+func (h *SpatialHash[T]) Len() int {
+	return len(h.positions)
+}
+
+// Insert adds an item at a position. If the item is already present, it is
+// moved.
+func (h *SpatialHash[T]) Insert(item T, p Point) {
+	if old, ok := h.positions[item]; ok {
+		if h.cell(old) == h.cell(p) {
+			h.positions[item] = p
+			return
+		}
+		h.removeFromCell(item, old)
+	}
+	h.positions[item] = p
+	c := h.cell(p)
+	h.cells[c] = append(h.cells[c], item)
+}
+
+// Move moves an item to a new position. It returns false if the item is not
+// present.
+func (h *SpatialHash[T]) Move(item T, p Point) bool {
+	if _, ok := h.positions[item]; !ok {
+		return false
+	}
+	h.Insert(item, p)
+	return true
+}
+
+// Remove removes an item. It returns false if the item was not present.
+func (h *SpatialHash[T]) Remove(item T) bool {
+	p, ok := h.positions[item]
+	if !ok {
+		return false
+	}
+	delete(h.positions, item)
+	h.removeFromCell(item, p)
+	return true
+}
+
+func (h *SpatialHash[T]) removeFromCell(item T, p Point) {
+	c := h.cell(p)
+	items := h.cells[c]
+	for i, other := range items {
+		if other == item {
+			items[i] = items[len(items)-1]
+			items = items[:len(items)-1]
+			break
+		}
+	}
+	if len(items) == 0 {
+		delete(h.cells, c)
+		return
+	}
+	h.cells[c] = items
+}
+
+// Position returns the position of an item.
+func (h *SpatialHash[T]) Position(item T) (Point, bool) {
+	p, ok := h.positions[item]
+	return p, ok
+}
+
+// At returns the items at a position.
+func (h *SpatialHash[T]) At(p Point) []T {
+	var items []T
+	for _, item := range h.cells[h.cell(p)] {
+		if h.positions[item] == p {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// InRect returns the items within a range, for example a Camera's viewport.
+func (h *SpatialHash[T]) InRect(rg Rect) []T {
+	var items []T
+	if rg.Empty() {
+		return items
+	}
+	h.eachCell(h.cell(rg.Min), h.cell(rg.Max.Sub(Point{X: 1, Y: 1})), func(item T, p Point) {
+		if p.In(rg) {
+			items = append(items, item)
+		}
+	})
+	return items
+}
+
+// InRadius returns the items within a radius of a position. A position is
+// within the radius if it is inside or on the outline returned by
+// CircleAround for that radius.
+func (h *SpatialHash[T]) InRadius(center Point, radius int) []T {
+	var items []T
+	offset := Point{X: radius, Y: radius}
+	h.eachCell(h.cell(center.Sub(offset)), h.cell(center.Add(offset)), func(item T, p Point) {
+		if int(Distance(p, center)+0.5) <= radius {
+			items = append(items, item)
+		}
+	})
+	return items
+}
+
+// Where returns the items for which keep returns true, for example the
+// items in the field of view of the player.
+func (h *SpatialHash[T]) Where(keep func(item T, p Point) bool) []T {
+	var items []T
+	for item, p := range h.positions {
+		if keep(item, p) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Nearest returns up to k items closest to a position in Euclidean
+// distance, closest first. Items at the same distance are ordered by
+// position, top to bottom and left to right.
+func (h *SpatialHash[T]) Nearest(center Point, k int) []T {
+	if k <= 0 || len(h.positions) == 0 {
+		return nil
+	}
+	type candidate struct {
+		item     T
+		p        Point
+		distance int
+	}
+	var candidates []candidate
+	less := func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		return a.p.Y < b.p.Y || a.p.Y == b.p.Y && a.p.X < b.p.X
+	}
+	c := h.cell(center)
+	visited := 0
+	// Search rings of cells around the center, until the k-th candidate is
+	// closer than any item in the next ring could be.
+	for ring := 0; visited < len(h.cells); ring++ {
+		for y := c.Y - ring; y <= c.Y+ring; y++ {
+			for x := c.X - ring; x <= c.X+ring; x++ {
+				if ring > 0 && y != c.Y-ring && y != c.Y+ring && x != c.X-ring && x != c.X+ring {
+					continue
+				}
+				items, ok := h.cells[Point{X: x, Y: y}]
+				if !ok {
+					continue
+				}
+				visited++
+				for _, item := range items {
+					p := h.positions[item]
+					candidates = append(candidates, candidate{item: item, p: p, distance: DistanceSquared(p, center)})
+				}
+			}
+		}
+		if len(candidates) >= k {
+			sort.Slice(candidates, less)
+			reach := ring * h.cellSize
+			if candidates[k-1].distance <= reach*reach {
+				break
+			}
+		}
+	}
+	sort.Slice(candidates, less)
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	items := make([]T, len(candidates))
+	for i, cand := range candidates {
+		items[i] = cand.item
+	}
+	return items
+}
+
+// eachCell calls fn for every item in the cells between two cells,
+// inclusive.
+func (h *SpatialHash[T]) eachCell(min, max Point, fn func(item T, p Point)) {
+	for y := min.Y; y <= max.Y; y++ {
+		for x := min.X; x <= max.X; x++ {
+			for _, item := range h.cells[Point{X: x, Y: y}] {
+				fn(item, h.positions[item])
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package steering
+
+import "github.com/memmaker/go/geometry"
+
+// The flocking behaviors take into account the other agents within radius
+// of the agent. The agent itself can be part of the others, and is ignored.
+
+// Separation returns the force moving the agent away from close neighbors,
+// the closer ones more strongly.
+func Separation(a *Agent, others []*Agent, radius float64) geometry.PointF {
+	var away geometry.PointF
+	for _, o := range others {
+		if o == a {
+			continue
+		}
+		offset := a.Position.Sub(o.Position)
+		dist := offset.Len()
+		// Agents on the same position give no way to tell which way to go.
+		if dist >= radius || dist == 0 {
+			continue
+		}
+		away = away.Add(offset.Mul(1 / (dist * dist)))
+	}
+	if away == (geometry.PointF{}) {
+		return geometry.PointF{}
+	}
+	return a.steer(away.Normalize().Mul(a.MaxSpeed))
+}
+
+// Cohesion returns the force moving the agent towards the center of its
+// neighbors.
+func Cohesion(a *Agent, others []*Agent, radius float64) geometry.PointF {
+	var center geometry.PointF
+	n := 0
+	for _, o := range others {
+		if o != a && o.Position.Sub(a.Position).Len() < radius {
+			center = center.Add(o.Position)
+			n++
+		}
+	}
+	if n == 0 {
+		return geometry.PointF{}
+	}
+	return Seek(a, center.Div(float64(n)))
+}
+
+// Alignment returns the force turning the agent towards the average heading
+// of its neighbors.
+func Alignment(a *Agent, others []*Agent, radius float64) geometry.PointF {
+	var heading geometry.PointF
+	n := 0
+	for _, o := range others {
+		if o != a && o.Position.Sub(a.Position).Len() < radius {
+			heading = heading.Add(o.Velocity)
+			n++
+		}
+	}
+	if n == 0 || heading == (geometry.PointF{}) {
+		return geometry.PointF{}
+	}
+	return a.steer(heading.Normalize().Mul(a.MaxSpeed))
+}
@@ -0,0 +1,144 @@
+package steering
+
+import (
+	"github.com/memmaker/go/geometry"
+	"math"
+	"sort"
+)
+
+// Formation is a set of slots relative to a leader. Slot offsets are given
+// for a leader heading east (positive X), with positive Y on its right, and
+// are rotated with the leader's heading. The leader itself has no slot.
+type Formation struct {
+	Slots []geometry.PointF
+}
+
+// LineFormation returns n slots abreast of the leader, alternately on its
+// right and left, spacing apart.
+func LineFormation(n int, spacing float64) Formation {
+	slots := make([]geometry.PointF, n)
+	for i := range slots {
+		rank := float64(i/2 + 1)
+		if i%2 == 1 {
+			rank = -rank
+		}
+		slots[i] = geometry.PointF{Y: rank * spacing}
+	}
+	return Formation{Slots: slots}
+}
+
+// ColumnFormation returns n slots in single file behind the leader.
+func ColumnFormation(n int, spacing float64) Formation {
+	slots := make([]geometry.PointF, n)
+	for i := range slots {
+		slots[i] = geometry.PointF{X: -float64(i+1) * spacing}
+	}
+	return Formation{Slots: slots}
+}
+
+// WedgeFormation returns n slots in a V behind the leader, alternately on
+// its right and left.
+func WedgeFormation(n int, spacing float64) Formation {
+	slots := make([]geometry.PointF, n)
+	for i := range slots {
+		rank := float64(i/2 + 1)
+		side := 1.0
+		if i%2 == 1 {
+			side = -1
+		}
+		slots[i] = geometry.PointF{X: -rank * spacing, Y: side * rank * spacing}
+	}
+	return Formation{Slots: slots}
+}
+
+// BoxFormation returns n slots in rows of a given width behind the leader.
+func BoxFormation(n, width int, spacing float64) Formation {
+	width = max(1, width)
+	slots := make([]geometry.PointF, n)
+	for i := range slots {
+		row, col := i/width, i%width
+		slots[i] = geometry.PointF{
+			X: -float64(row+1) * spacing,
+			Y: (float64(col) - float64(width-1)/2) * spacing,
+		}
+	}
+	return Formation{Slots: slots}
+}
+
+// CircleFormation returns n slots evenly spread on a circle around the
+// leader, the first one in front of it.
+func CircleFormation(n int, radius float64) Formation {
+	slots := make([]geometry.PointF, n)
+	for i := range slots {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		slots[i] = geometry.PointF{X: cos * radius, Y: sin * radius}
+	}
+	return Formation{Slots: slots}
+}
+
+// Positions returns the positions of the slots for a leader position and
+// heading. A zero heading is taken as east.
+func (f Formation) Positions(leader, heading geometry.PointF) []geometry.PointF {
+	heading = heading.Normalize()
+	if heading == (geometry.PointF{}) {
+		heading = geometry.PointF{X: 1}
+	}
+	ps := make([]geometry.PointF, len(f.Slots))
+	for i, s := range f.Slots {
+		ps[i] = geometry.PointF{
+			X: leader.X + s.X*heading.X - s.Y*heading.Y,
+			Y: leader.Y + s.X*heading.Y + s.Y*heading.X,
+		}
+	}
+	return ps
+}
+
+// Assign assigns agents to the slots of the formation, for a leader
+// position and heading, so that agents go to close slots. It returns the
+// slot index of each agent, or -1 for agents left without slot when there
+// are more agents than slots.
+//
+// Closest agent and slot pairs are assigned first, which keeps agents from
+// crossing the whole formation when the leader turns.
+func (f Formation) Assign(leader, heading geometry.PointF, agents []*Agent) []int {
+	ps := f.Positions(leader, heading)
+	type pair struct {
+		agent, slot int
+		dist        float64
+	}
+	pairs := make([]pair, 0, len(agents)*len(ps))
+	for i, a := range agents {
+		for j, p := range ps {
+			pairs = append(pairs, pair{agent: i, slot: j, dist: p.Sub(a.Position).Len()})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].dist < pairs[j].dist
+	})
+	slots := make([]int, len(agents))
+	for i := range slots {
+		slots[i] = -1
+	}
+	taken := make([]bool, len(ps))
+	for _, pr := range pairs {
+		if slots[pr.agent] < 0 && !taken[pr.slot] {
+			slots[pr.agent] = pr.slot
+			taken[pr.slot] = true
+		}
+	}
+	return slots
+}
+
+// Keep returns the forces moving agents to their assigned slots, as
+// returned by Assign, arriving within slowRadius. Agents without slot get a
+// zero force.
+func (f Formation) Keep(leader, heading geometry.PointF, agents []*Agent, slots []int, slowRadius float64) []geometry.PointF {
+	ps := f.Positions(leader, heading)
+	forces := make([]geometry.PointF, len(agents))
+	for i, a := range agents {
+		if i < len(slots) && slots[i] >= 0 && slots[i] < len(ps) {
+			forces[i] = Arrive(a, ps[slots[i]], slowRadius)
+		}
+	}
+	return forces
+}
@@ -0,0 +1,56 @@
+package steering
+
+import "github.com/memmaker/go/geometry"
+
+// PathFollower steers an agent along a path, for example one returned by
+// PathRange.AstarPath, seeking each waypoint in turn and arriving at the
+// last one.
+type PathFollower struct {
+	Waypoints []geometry.PointF
+	// Radius is the distance at which a waypoint counts as reached, so that
+	// the agent can cut corners instead of going through every cell center.
+	Radius float64
+	// SlowRadius is the slowing distance used to arrive at the last waypoint.
+	SlowRadius float64
+	current    int
+}
+
+// NewPathFollower returns a follower for a path of grid cells, going
+// through their centers. The first cell, usually the start of the path, is
+// not skipped: the agent reaches it first if it is not there yet.
+func NewPathFollower(path []geometry.Point) *PathFollower {
+	waypoints := make([]geometry.PointF, len(path))
+	for i, p := range path {
+		waypoints[i] = p.ToCenteredPointF()
+	}
+	return &PathFollower{Waypoints: waypoints, Radius: 0.5, SlowRadius: 2}
+}
+
+// Current returns the index of the waypoint the agent is heading to.
+func (pf *PathFollower) Current() int {
+	return pf.current
+}
+
+// Done returns true when the agent has reached the last waypoint.
+func (pf *PathFollower) Done(a *Agent) bool {
+	if len(pf.Waypoints) == 0 {
+		return true
+	}
+	last := pf.Waypoints[len(pf.Waypoints)-1]
+	return pf.current == len(pf.Waypoints)-1 && last.Sub(a.Position).Len() < pf.Radius
+}
+
+// Follow returns the force moving the agent along the path.
+func (pf *PathFollower) Follow(a *Agent) geometry.PointF {
+	if len(pf.Waypoints) == 0 {
+		return geometry.PointF{}
+	}
+	last := len(pf.Waypoints) - 1
+	for pf.current < last && pf.Waypoints[pf.current].Sub(a.Position).Len() < pf.Radius {
+		pf.current++
+	}
+	if pf.current == last {
+		return Arrive(a, pf.Waypoints[last], pf.SlowRadius)
+	}
+	return Seek(a, pf.Waypoints[pf.current])
+}
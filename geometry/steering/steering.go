@@ -0,0 +1,104 @@
+// Package steering implements Reynolds-style steering behaviors for agents
+// moving in continuous space over the grid, and formations assigning agents
+// to slots relative to a leader.
+//
+// Behaviors return a steering force, which can be weighted and added to
+// other forces before being applied with Agent.Update. Positions are
+// geometry.PointF values, with the center of cell (x, y) at (x+0.5, y+0.5).
+package steering
+
+import (
+	"github.com/memmaker/go/geometry"
+	"math"
+	"math/rand"
+)
+
+// Agent is a moving entity with a unit mass.
+type Agent struct {
+	Position geometry.PointF
+	Velocity geometry.PointF
+	MaxSpeed float64 // in cells per second
+	MaxForce float64 // largest steering force, in cells per second squared
+}
+
+// Update applies a steering force to the agent for dt seconds.
+func (a *Agent) Update(force geometry.PointF, dt float64) {
+	force = force.Truncate(a.MaxForce)
+	a.Velocity = a.Velocity.Add(force.Mul(dt)).Truncate(a.MaxSpeed)
+	a.Position = a.Position.Add(a.Velocity.Mul(dt))
+}
+
+// Heading returns the direction of the agent's velocity, or the zero vector
+// if it does not move.
+func (a *Agent) Heading() geometry.PointF {
+	return a.Velocity.Normalize()
+}
+
+// steer returns the force turning the agent's velocity towards a desired
+// velocity.
+func (a *Agent) steer(desired geometry.PointF) geometry.PointF {
+	return desired.Sub(a.Velocity).Truncate(a.MaxForce)
+}
+
+// Seek returns the force moving the agent towards a target at full speed.
+func Seek(a *Agent, target geometry.PointF) geometry.PointF {
+	return a.steer(target.Sub(a.Position).Normalize().Mul(a.MaxSpeed))
+}
+
+// Flee returns the force moving the agent away from a threat at full speed,
+// as long as the threat is closer than panicDistance. A zero panicDistance
+// flees from any distance.
+func Flee(a *Agent, threat geometry.PointF, panicDistance float64) geometry.PointF {
+	away := a.Position.Sub(threat)
+	if panicDistance > 0 && away.Len() > panicDistance {
+		return geometry.PointF{}
+	}
+	return a.steer(away.Normalize().Mul(a.MaxSpeed))
+}
+
+// Arrive is like Seek, but the agent slows down within slowRadius of the
+// target, so that it stops on it instead of overshooting it.
+func Arrive(a *Agent, target geometry.PointF, slowRadius float64) geometry.PointF {
+	offset := target.Sub(a.Position)
+	dist := offset.Len()
+	if dist == 0 {
+		return a.steer(geometry.PointF{})
+	}
+	speed := a.MaxSpeed
+	if dist < slowRadius {
+		speed *= dist / slowRadius
+	}
+	return a.steer(offset.Mul(speed / dist))
+}
+
+// Wanderer produces a random walk with smooth turns: it seeks a target
+// moving randomly on a circle in front of the agent.
+type Wanderer struct {
+	Distance float64 // distance of the circle center in front of the agent
+	Radius   float64 // radius of the circle
+	Jitter   float64 // largest change of the target angle per call, in radians
+	angle    float64
+}
+
+// NewWanderer returns a wanderer with a circle of a given radius, at twice
+// the radius in front of the agent.
+func NewWanderer(radius float64) *Wanderer {
+	return &Wanderer{Distance: 2 * radius, Radius: radius, Jitter: 0.5}
+}
+
+// Wander returns the force of the next wandering step. A nil rnd uses the
+// default source of math/rand.
+func (w *Wanderer) Wander(a *Agent, rnd *rand.Rand) geometry.PointF {
+	r := rand.Float64
+	if rnd != nil {
+		r = rnd.Float64
+	}
+	w.angle += (2*r() - 1) * w.Jitter
+	heading := a.Heading()
+	if heading == (geometry.PointF{}) {
+		heading = geometry.PointF{X: 1}
+	}
+	center := a.Position.Add(heading.Mul(w.Distance))
+	sin, cos := math.Sincos(w.angle)
+	return Seek(a, center.Add(geometry.PointF{X: cos * w.Radius, Y: sin * w.Radius}))
+}
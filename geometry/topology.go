@@ -0,0 +1,348 @@
+package geometry
+
+import "math"
+
+// Topology describes how the edges of a map of a given size connect. With
+// WrapX, leaving the map on the east edge enters it on the west edge and
+// conversely, as on a planet overworld. With WrapY the same goes for the
+// north and south edges, and with both the map is a torus. The zero value
+// does not wrap.
+//
+// Path finding works unchanged on wrapping maps with PathRange's algorithms
+// on the map's Range, as long as the Pather uses a WrapNeighbors and the
+// Astar estimation one of Topology's distances. JPSPath and the FOV
+// algorithms need the Topology's versions.
+type Topology struct {
+	Size  Point
+	WrapX bool
+	WrapY bool
+}
+
+// NewTorus returns a topology wrapping in both directions.
+func NewTorus(size Point) Topology {
+	return Topology{Size: size, WrapX: true, WrapY: true}
+}
+
+// NewCylinder returns a topology wrapping east-west only.
+func NewCylinder(size Point) Topology {
+	return Topology{Size: size, WrapX: true}
+}
+
+// Range returns the range of positions of the map.
+func (t Topology) Range() Rect {
+	return NewRect(0, 0, t.Size.X, t.Size.Y)
+}
+
+// Wrap returns the position on the map corresponding to a position beyond
+// its wrapping edges. Positions beyond the other edges are not changed.
+func (t Topology) Wrap(p Point) Point {
+	if t.WrapX {
+		p.X = mod(p.X, t.Size.X)
+	}
+	if t.WrapY {
+		p.Y = mod(p.Y, t.Size.Y)
+	}
+	return p
+}
+
+func mod(a, n int) int {
+	a %= n
+	if a < 0 {
+		a += n
+	}
+	return a
+}
+
+// Contains returns true if a position is on the map after wrapping.
+func (t Topology) Contains(p Point) bool {
+	return t.Wrap(p).In(t.Range())
+}
+
+// Add returns the wrapped position p+q.
+func (t Topology) Add(p, q Point) Point {
+	return t.Wrap(p.Add(q))
+}
+
+// Delta returns the shortest vector from a position to another, taking the
+// wrapping edges into account.
+func (t Topology) Delta(from, to Point) Point {
+	d := to.Sub(from)
+	if t.WrapX {
+		d.X = mod(d.X, t.Size.X)
+		if d.X > t.Size.X/2 {
+			d.X -= t.Size.X
+		}
+	}
+	if t.WrapY {
+		d.Y = mod(d.Y, t.Size.Y)
+		if d.Y > t.Size.Y/2 {
+			d.Y -= t.Size.Y
+		}
+	}
+	return d
+}
+
+// DistanceManhattan is like DistanceManhattan, the wrapping edges taken
+// into account.
+func (t Topology) DistanceManhattan(p, q Point) int {
+	d := t.Delta(p, q)
+	return Abs(d.X) + Abs(d.Y)
+}
+
+// DistanceChebyshev is like DistanceChebyshev, the wrapping edges taken
+// into account.
+func (t Topology) DistanceChebyshev(p, q Point) int {
+	d := t.Delta(p, q)
+	return max(Abs(d.X), Abs(d.Y))
+}
+
+// DistanceSquared is like DistanceSquared, the wrapping edges taken into
+// account.
+func (t Topology) DistanceSquared(p, q Point) int {
+	d := t.Delta(p, q)
+	return d.X*d.X + d.Y*d.Y
+}
+
+// Distance is like Distance, the wrapping edges taken into account.
+func (t Topology) Distance(p, q Point) float64 {
+	return math.Sqrt(float64(t.DistanceSquared(p, q)))
+}
+
+// window returns a range around a center, up to radius on the wrapping
+// axes and limited to the map on the others. Positions of the map appear
+// several times in the window if it is larger than the map.
+func (t Topology) window(center Point, radius int) Rect {
+	rg := t.Range()
+	if t.WrapX {
+		rg.Min.X, rg.Max.X = center.X-radius, center.X+radius+1
+	}
+	if t.WrapY {
+		rg.Min.Y, rg.Max.Y = center.Y-radius, center.Y+radius+1
+	}
+	return rg
+}
+
+// JPSPath is like PathRange.JPSPath on a wrapping map, with pr's range
+// being the map's range. The returned path is made of wrapped positions.
+//
+// The search takes place on a window in which the map is repeated, spanning
+// one map size on each wrapping axis, from the copy of to on one side of from
+// to the copy on the other side, and the shortest path towards the copies is
+// returned. Paths that would leave the window, going around both copies, are
+// not found, so that the path may be a bit longer than the shortest one. If
+// no path is found in the window, AstarPath is used on the map instead.
+func (t Topology) JPSPath(pr *PathRange, path []Point, from, to Point, passable func(Point) bool, diags bool) []Point {
+	rg := pr.Range()
+	if !from.In(rg) || !to.In(rg) {
+		return nil
+	}
+	best := t.jpsWindowPath(pr, from, to, passable, diags)
+	if best == nil {
+		ast := &wrapAstar{t: t, passable: passable, diags: diags, nb: WrapNeighbors{Topology: t}}
+		return append(path[:0], pr.AstarPath(ast, from, to)...)
+	}
+	return append(path[:0], best...)
+}
+
+// jpsWindowPath returns the shortest path found by JPSPath on the window of
+// Topology.JPSPath, with wrapped positions, and restores pr's range.
+func (t Topology) jpsWindowPath(pr *PathRange, from, to Point, passable func(Point) bool, diags bool) []Point {
+	d := t.Delta(from, to)
+	dxs, dys := []int{d.X}, []int{d.Y}
+	window := t.Range()
+	if t.WrapX {
+		window.Min.X, window.Max.X = jpsSpan(from.X, d.X, t.Size.X)
+		if d.X != 0 {
+			dxs = append(dxs, d.X-sign(d.X)*t.Size.X)
+		}
+	}
+	if t.WrapY {
+		window.Min.Y, window.Max.Y = jpsSpan(from.Y, d.Y, t.Size.Y)
+		if d.Y != 0 {
+			dys = append(dys, d.Y-sign(d.Y)*t.Size.Y)
+		}
+	}
+	// The window is translated to start at the origin, as JPSPath does not
+	// always find the shortest path with negative coordinates.
+	rg := pr.Range()
+	offset := window.Min
+	pr.SetRange(window.Sub(offset))
+	defer pr.SetRange(rg)
+	windowPassable := func(p Point) bool {
+		return passable(t.Wrap(p.Add(offset)))
+	}
+	var best, candidate []Point
+	for _, dx := range dxs {
+		for _, dy := range dys {
+			target := from.Add(Point{X: dx, Y: dy})
+			candidate = pr.JPSPath(candidate, from.Sub(offset), target.Sub(offset), windowPassable, diags)
+			if candidate != nil && (best == nil || len(candidate) < len(best)) {
+				best = append(best[:0], candidate...)
+			}
+		}
+	}
+	for i, p := range best {
+		best[i] = t.Wrap(p.Add(offset))
+	}
+	return best
+}
+
+// jpsSpan returns the bounds on a wrapping axis of the window of JPSPath, for
+// a start coordinate and a shortest delta to the goal: the window goes from
+// one copy of the goal to the other, or is centered on the start if the
+// delta is 0.
+func jpsSpan(start, delta, size int) (int, int) {
+	lo := -size / 2
+	if delta > 0 {
+		lo = delta - size
+	} else if delta < 0 {
+		lo = delta
+	}
+	return start + lo, start + lo + size + 1
+}
+
+// wrapAstar is the Astar used by JPSPath when no path was found in the
+// window, with the same moves.
+type wrapAstar struct {
+	t        Topology
+	passable func(Point) bool
+	diags    bool
+	nb       WrapNeighbors
+}
+
+func (w *wrapAstar) Neighbors(p Point) []Point {
+	if !w.passable(p) {
+		return nil
+	}
+	if w.diags {
+		return w.nb.All(p, w.passable)
+	}
+	return w.nb.Cardinal(p, w.passable)
+}
+
+func (w *wrapAstar) Cost(p, q Point) int {
+	return 1
+}
+
+func (w *wrapAstar) Estimation(p, q Point) int {
+	if w.diags {
+		return w.t.DistanceChebyshev(p, q)
+	}
+	return w.t.DistanceManhattan(p, q)
+}
+
+// VisionMap is like FOV.VisionMap on a wrapping map. The returned nodes have
+// wrapped positions, and are not cached. Other FOV methods, like At and Ray,
+// use the positions of the range the computation took place in, which is a
+// window of radius lt.MaxCost(src) around src on the wrapping axes.
+func (t Topology) VisionMap(fov *FOV, lt Lighter, src Point) []LightNode {
+	radius := int(math.Ceil(lt.MaxCost(src)))
+	fov.SetRange(t.window(src, radius))
+	nodes := fov.VisionMap(wrappedLighter{Lighter: lt, t: t}, src)
+	// Positions seen through several edges are kept with their lowest cost.
+	seen := make(map[Point]int, len(nodes))
+	result := make([]LightNode, 0, len(nodes))
+	for _, n := range nodes {
+		n.P = t.Wrap(n.P)
+		if i, ok := seen[n.P]; ok {
+			result[i].Cost = math.Min(result[i].Cost, n.Cost)
+			continue
+		}
+		seen[n.P] = len(result)
+		result = append(result, n)
+	}
+	return result
+}
+
+type wrappedLighter struct {
+	Lighter
+	t Topology
+}
+
+func (l wrappedLighter) Cost(src Point, from Point, to Point) float64 {
+	return l.Lighter.Cost(l.t.Wrap(src), l.t.Wrap(from), l.t.Wrap(to))
+}
+
+func (l wrappedLighter) MaxCost(src Point) float64 {
+	return l.Lighter.MaxCost(l.t.Wrap(src))
+}
+
+// SSCVisionMap is like FOV.SSCVisionMap on a wrapping map. The returned
+// positions are wrapped and not cached. As with VisionMap, Visible uses the
+// positions of the window the computation took place in.
+func (t Topology) SSCVisionMap(fov *FOV, src Point, maxDepth int, diags bool, passable func(p Point) bool) []Point {
+	fov.SetRange(t.window(src, maxDepth))
+	ps := fov.SSCVisionMap(src, maxDepth, diags, func(p Point) bool {
+		return passable(t.Wrap(p))
+	})
+	seen := make(map[Point]bool, len(ps))
+	result := make([]Point, 0, len(ps))
+	for _, p := range ps {
+		p = t.Wrap(p)
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// WrapNeighbors fetches adjacent positions on a wrapping map. Like
+// Neighbors, its methods return a cached slice for efficiency, so results
+// are invalidated by next method calls. It is suitable for use in
+// satisfying the Dijkstra, Astar and Pather interfaces on wrapping maps.
+type WrapNeighbors struct {
+	Topology Topology
+	ps       []Point
+}
+
+// All returns 8 adjacent positions, including diagonal ones, wrapped and
+// filtered by keep function. Positions beyond edges which do not wrap are
+// left out.
+func (nb *WrapNeighbors) All(p Point, keep func(Point) bool) []Point {
+	nb.ps = nb.ps[:0]
+	for y := -1; y <= 1; y++ {
+		for x := -1; x <= 1; x++ {
+			if x != 0 || y != 0 {
+				nb.add(p.Shift(x, y), keep)
+			}
+		}
+	}
+	return nb.ps
+}
+
+// Cardinal returns 4 adjacent cardinal positions, wrapped and filtered by
+// keep function.
+func (nb *WrapNeighbors) Cardinal(p Point, keep func(Point) bool) []Point {
+	nb.ps = nb.ps[:0]
+	for i := -1; i <= 1; i += 2 {
+		nb.add(p.Shift(i, 0), keep)
+		nb.add(p.Shift(0, i), keep)
+	}
+	return nb.ps
+}
+
+// Diagonal returns 4 adjacent diagonal positions, wrapped and filtered by
+// keep function.
+func (nb *WrapNeighbors) Diagonal(p Point, keep func(Point) bool) []Point {
+	nb.ps = nb.ps[:0]
+	for y := -1; y <= 1; y += 2 {
+		for x := -1; x <= 1; x += 2 {
+			nb.add(p.Shift(x, y), keep)
+		}
+	}
+	return nb.ps
+}
+
+func (nb *WrapNeighbors) add(q Point, keep func(Point) bool) {
+	q = nb.Topology.Wrap(q)
+	if !q.In(nb.Topology.Range()) || !keep(q) {
+		return
+	}
+	for _, other := range nb.ps {
+		if other == q {
+			return // on maps 2 positions wide or less
+		}
+	}
+	nb.ps = append(nb.ps, q)
+}
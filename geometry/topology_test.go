@@ -0,0 +1,46 @@
+package geometry
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestTopologyJPSPath compares Topology.JPSPath with AstarPath on wrapping
+// maps with random walls.
+func TestTopologyJPSPath(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 40; i++ {
+		top := NewTorus(Point{X: 12 + random.Intn(20), Y: 8 + random.Intn(15)})
+		if i%3 == 0 {
+			top = NewCylinder(top.Size)
+		}
+		walls := map[Point]bool{}
+		for k := 0; k < top.Size.X*top.Size.Y/4; k++ {
+			walls[Point{X: random.Intn(top.Size.X), Y: random.Intn(top.Size.Y)}] = true
+		}
+		passable := func(p Point) bool { return !walls[p] }
+		ast := &wrapAstar{t: top, passable: passable, diags: i%2 == 0, nb: WrapNeighbors{Topology: top}}
+		pr, astarPr := NewPathRange(top.Range()), NewPathRange(top.Range())
+		for k := 0; k < 30; k++ {
+			from := Point{X: random.Intn(top.Size.X), Y: random.Intn(top.Size.Y)}
+			to := Point{X: random.Intn(top.Size.X), Y: random.Intn(top.Size.Y)}
+			if walls[from] || walls[to] {
+				continue
+			}
+			want := astarPr.AstarPath(ast, from, to)
+			path := top.JPSPath(pr, nil, from, to, passable, ast.diags)
+			if (path == nil) != (want == nil) {
+				t.Fatalf("map %d, %v to %v: got path %v, want %v", i, from, to, path, want)
+			}
+			if pr.Range() != top.Range() {
+				t.Fatalf("map %d: range not restored: %v", i, pr.Range())
+			}
+			for j := 1; j < len(path); j++ {
+				if !passable(path[j]) || top.DistanceChebyshev(path[j-1], path[j]) != 1 ||
+					!ast.diags && top.DistanceManhattan(path[j-1], path[j]) != 1 {
+					t.Fatalf("map %d, %v to %v: invalid path %v", i, from, to, path)
+				}
+			}
+		}
+	}
+}
@@ -379,6 +379,20 @@ func (f PointF) Mul(scalar float64) PointF {
 func (f PointF) MulInt(scalar int) PointF {
 	return f.Mul(float64(scalar))
 }
+
+// Len returns the length of the vector.
+func (f PointF) Len() float64 {
+	return math.Hypot(f.X, f.Y)
+}
+
+// Truncate returns the vector limited to a maximal length.
+func (f PointF) Truncate(max float64) PointF {
+	if l := f.Len(); l > max && l > 0 {
+		return f.Mul(max / l)
+	}
+	return f
+}
+
 func (f PointF) Normalize() PointF {
 	length := math.Sqrt(f.X*f.X + f.Y*f.Y)
 	if length == 0 {
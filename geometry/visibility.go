@@ -0,0 +1,297 @@
+package geometry
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Visibility is the state of a position in a VisibilityMemory.
+type Visibility uint8
+
+const (
+	// Unseen positions have never been visible.
+	Unseen Visibility = iota
+	// Remembered positions have been visible before, but are not anymore.
+	Remembered
+	// Visible positions are currently visible.
+	Visible
+	// hiding is used temporarily by Update for positions visible in the
+	// previous turn.
+	hiding
+)
+
+func (v Visibility) String() string {
+	switch v {
+	case Remembered:
+		return "Remembered"
+	case Visible:
+		return "Visible"
+	}
+	return "Unseen"
+}
+
+// VisibilityMemory tracks which positions of a range are currently visible
+// and which have been seen before, the "explored" map of a roguelike. For
+// every seen position, it keeps a snapshot of what was there when it was
+// last visible, of any type T, for example a tile and the entities on it,
+// so that remembered positions can be drawn as they were last seen.
+//
+// Each Update is a turn: the differences with the previous turn are
+// available with NewlyVisible and NewlyHidden.
+//
+// VisibilityMemory elements must be created with NewVisibilityMemory.
+//
+// VisibilityMemory implements the gob.Decoder and gob.Encoder interfaces
+// for easy serialization, as long as T can be encoded by gob.
+type VisibilityMemory[T any] struct {
+	innerVisibilityMemory[T]
+}
+
+type innerVisibilityMemory[T any] struct {
+	Rg           Rect
+	States       []Visibility
+	Snapshots    []T
+	LastSeenTurn []int
+	Turn         int
+	Visibles     []Point
+	NewVisibles  []Point
+	NewHiddens   []Point
+	Src          Point
+	Valid        bool // whether Visibles is up to date for Src
+}
+
+// NewVisibilityMemory returns a new visibility memory for a range of
+// positions, with all positions unseen.
+func NewVisibilityMemory[T any](rg Rect) *VisibilityMemory[T] {
+	m := &VisibilityMemory[T]{}
+	m.Rg = rg
+	size := rg.Size()
+	m.States = make([]Visibility, size.X*size.Y)
+	m.Snapshots = make([]T, size.X*size.Y)
+	m.LastSeenTurn = make([]int, size.X*size.Y)
+	return m
+}
+
+// GobDecode implements gob.GobDecoder.
+func (m *VisibilityMemory[T]) GobDecode(bs []byte) error {
+	r := bytes.NewReader(bs)
+	gd := gob.NewDecoder(r)
+	im := &innerVisibilityMemory[T]{}
+	err := gd.Decode(im)
+	if err != nil {
+		return err
+	}
+	m.innerVisibilityMemory = *im
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder.
+func (m *VisibilityMemory[T]) GobEncode() ([]byte, error) {
+	buf := bytes.Buffer{}
+	ge := gob.NewEncoder(&buf)
+	err := ge.Encode(&m.innerVisibilityMemory)
+	return buf.Bytes(), err
+}
+
+// Range returns the range of tracked positions.
+func (m *VisibilityMemory[T]) Range() Rect {
+	return m.Rg
+}
+
+func (m *VisibilityMemory[T]) idx(p Point) int {
+	p = p.Sub(m.Rg.Min)
+	w := m.Rg.Max.X - m.Rg.Min.X
+	return p.Y*w + p.X
+}
+
+// CurrentTurn returns the number of updates so far.
+func (m *VisibilityMemory[T]) CurrentTurn() int {
+	return m.Turn
+}
+
+// Update starts a new turn in which exactly the given positions are visible.
+// Positions out of range are ignored. The snapshot function, if not nil, is
+// called for every visible position to record what is there.
+func (m *VisibilityMemory[T]) Update(visibles []Point, snapshot func(Point) T) {
+	m.Turn++
+	m.Valid = false
+	m.NewVisibles = m.NewVisibles[:0]
+	m.NewHiddens = m.NewHiddens[:0]
+	// Mark the previously visible positions as hiding, so that the
+	// differences need no extra storage.
+	for _, p := range m.Visibles {
+		m.States[m.idx(p)] = hiding
+	}
+	previous := m.Visibles
+	m.Visibles = make([]Point, 0, len(visibles))
+	for _, p := range visibles {
+		if !p.In(m.Rg) {
+			continue
+		}
+		i := m.idx(p)
+		switch m.States[i] {
+		case Visible:
+			continue // duplicate
+		case hiding:
+		default:
+			m.NewVisibles = append(m.NewVisibles, p)
+		}
+		m.States[i] = Visible
+		m.LastSeenTurn[i] = m.Turn
+		m.Visibles = append(m.Visibles, p)
+		if snapshot != nil {
+			m.Snapshots[i] = snapshot(p)
+		}
+	}
+	for _, p := range previous {
+		if i := m.idx(p); m.States[i] == hiding {
+			m.States[i] = Remembered
+			m.NewHiddens = append(m.NewHiddens, p)
+		}
+	}
+}
+
+// UpdateLightNodes is like Update, with the nodes returned by VisionMap or
+// LightMap. Nodes reached with a cost beyond maxCost, such as positions
+// behind walls, are not visible.
+func (m *VisibilityMemory[T]) UpdateLightNodes(nodes []LightNode, maxCost float64, snapshot func(Point) T) {
+	visibles := make([]Point, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Cost <= maxCost {
+			visibles = append(visibles, n.P)
+		}
+	}
+	m.Update(visibles, snapshot)
+}
+
+// UpdateVision computes the field of vision of a viewer at src with
+// FOV.VisionMap and updates the memory with it, up to lt.MaxCost(src). If
+// the viewer did not move since the last UpdateVision and no position was
+// invalidated with Invalidate, the field of vision is not recomputed: the
+// previous visible positions are reused and only their snapshots are
+// refreshed.
+func (m *VisibilityMemory[T]) UpdateVision(fov *FOV, lt Lighter, src Point, snapshot func(Point) T) {
+	if m.Valid && src == m.Src {
+		visibles := append([]Point(nil), m.Visibles...)
+		m.Update(visibles, snapshot)
+	} else {
+		m.UpdateLightNodes(fov.VisionMap(lt, src), lt.MaxCost(src), snapshot)
+	}
+	m.Src = src
+	m.Valid = true
+}
+
+// Invalidate forces the next UpdateVision to recompute the field of vision
+// if any of the given positions is visible or next to a visible position,
+// for example because a door opened or closed there.
+func (m *VisibilityMemory[T]) Invalidate(ps ...Point) {
+	for _, p := range ps {
+		for _, q := range [...]Point{p, p.Add(RelativeNorth), p.Add(RelativeEast), p.Add(RelativeSouth), p.Add(RelativeWest),
+			p.Add(Point{X: 1, Y: 1}), p.Add(Point{X: 1, Y: -1}), p.Add(Point{X: -1, Y: 1}), p.Add(Point{X: -1, Y: -1})} {
+			if m.IsVisible(q) {
+				m.Valid = false
+				return
+			}
+		}
+	}
+}
+
+// State returns the visibility state of a position. Positions out of range
+// are Unseen.
+func (m *VisibilityMemory[T]) State(p Point) Visibility {
+	if !p.In(m.Rg) {
+		return Unseen
+	}
+	return m.States[m.idx(p)]
+}
+
+// IsVisible returns true if a position is currently visible.
+func (m *VisibilityMemory[T]) IsVisible(p Point) bool {
+	return m.State(p) == Visible
+}
+
+// IsSeen returns true if a position is currently visible or has been
+// visible before.
+func (m *VisibilityMemory[T]) IsSeen(p Point) bool {
+	return m.State(p) != Unseen
+}
+
+// IsRemembered returns true if a position has been visible before, but is
+// not currently visible.
+func (m *VisibilityMemory[T]) IsRemembered(p Point) bool {
+	return m.State(p) == Remembered
+}
+
+// LastSeen returns the snapshot of a position taken when it was last
+// visible, and the turn of that update. It returns false if the position
+// has never been seen.
+func (m *VisibilityMemory[T]) LastSeen(p Point) (T, int, bool) {
+	var zero T
+	if !m.IsSeen(p) {
+		return zero, 0, false
+	}
+	i := m.idx(p)
+	return m.Snapshots[i], m.LastSeenTurn[i], true
+}
+
+// VisiblePositions returns the currently visible positions. The returned
+// slice should not be modified.
+func (m *VisibilityMemory[T]) VisiblePositions() []Point {
+	return m.Visibles
+}
+
+// NewlyVisible returns the positions which became visible in the last
+// update. The returned slice is invalidated by the next update.
+func (m *VisibilityMemory[T]) NewlyVisible() []Point {
+	return m.NewVisibles
+}
+
+// NewlyHidden returns the positions which stopped being visible in the last
+// update. The returned slice is invalidated by the next update.
+func (m *VisibilityMemory[T]) NewlyHidden() []Point {
+	return m.NewHiddens
+}
+
+// Reveal marks positions as seen without making them visible, as with a
+// magic map, recording their snapshot if snapshot is not nil.
+func (m *VisibilityMemory[T]) Reveal(ps []Point, snapshot func(Point) T) {
+	for _, p := range ps {
+		if !p.In(m.Rg) {
+			continue
+		}
+		i := m.idx(p)
+		if m.States[i] == Unseen {
+			m.States[i] = Remembered
+		}
+		if snapshot != nil {
+			m.Snapshots[i] = snapshot(p)
+			m.LastSeenTurn[i] = m.Turn
+		}
+	}
+}
+
+// Forget makes positions unseen again, as with an amnesia effect. Visible
+// positions are not affected.
+func (m *VisibilityMemory[T]) Forget(ps ...Point) {
+	var zero T
+	for _, p := range ps {
+		if !p.In(m.Rg) {
+			continue
+		}
+		i := m.idx(p)
+		if m.States[i] == Remembered {
+			m.States[i] = Unseen
+			m.Snapshots[i] = zero
+			m.LastSeenTurn[i] = 0
+		}
+	}
+}
+
+// Iter calls a function for every seen position, with its state.
+func (m *VisibilityMemory[T]) Iter(fn func(p Point, state Visibility)) {
+	m.Rg.Iter(func(p Point) {
+		if state := m.States[m.idx(p)]; state != Unseen {
+			fn(p, state)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package geometry
+
+import "math"
+
+// VisionCone restricts a field of vision to the directions around a facing
+// direction, for example the sight of a guard.
+type VisionCone struct {
+	Direction CompassDirection // facing, in degrees, see CompassDirection
+	Angle     float64          // full aperture of the cone, in degrees
+
+	// PeripheralCost is the extra light cost per step added by ConeLighter
+	// at the border of the cone. It grows quadratically from 0 in the
+	// facing direction, so that the sight range shrinks towards the
+	// border, like peripheral vision.
+	PeripheralCost float64
+}
+
+// Offset returns the angle in degrees between the facing direction and the
+// direction from src to p, from 0 to 180.
+func (c VisionCone) Offset(src, p Point) float64 {
+	if p == src {
+		return 0
+	}
+	d := math.Mod(DirectionVectorToAngleInDegrees(p.Sub(src))-float64(c.Direction), 360)
+	if d < 0 {
+		d += 360
+	}
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// Contains returns true if p is within the cone of a viewer at src. The
+// viewer position itself is always within the cone.
+func (c VisionCone) Contains(src, p Point) bool {
+	return c.Offset(src, p) <= c.Angle/2
+}
+
+// ConeLighter returns a Lighter restricting the light rays of lt to a
+// vision cone, with peripheral vision costs. It can be used with VisionMap
+// and LightMap, but ConeVisionMap should be preferred, as it also discards
+// the positions beyond the maximal cost.
+func ConeLighter(lt Lighter, cone VisionCone) Lighter {
+	return coneLighter{Lighter: lt, cone: cone}
+}
+
+type coneLighter struct {
+	Lighter
+	cone VisionCone
+}
+
+func (l coneLighter) Cost(src Point, from Point, to Point) float64 {
+	if !l.cone.Contains(src, to) {
+		return l.Lighter.MaxCost(src) + 1
+	}
+	cost := l.Lighter.Cost(src, from, to)
+	if l.cone.PeripheralCost > 0 && l.cone.Angle > 0 {
+		t := l.cone.Offset(src, to) / (l.cone.Angle / 2)
+		cost += l.cone.PeripheralCost * t * t
+	}
+	return cost
+}
+
+// ConeVisionMap is like VisionMap for a viewer at src facing a direction,
+// with light rays restricted to the vision cone and made more costly
+// towards its border, see VisionCone.PeripheralCost. Contrary to VisionMap,
+// positions which are reached with a cost beyond lt.MaxCost(src), for
+// example behind walls, are not included, neither in the returned cached
+// slice nor in At.
+func (fov *FOV) ConeVisionMap(lt Lighter, src Point, cone VisionCone) []LightNode {
+	clt := ConeLighter(lt, cone)
+	maxCost := lt.MaxCost(src)
+	nodes := fov.VisionMap(clt, src)
+	visibles := nodes[:0]
+	for _, n := range nodes {
+		if n.Cost > maxCost {
+			fov.Costs[fov.idx(n.P)] = 0
+			continue
+		}
+		visibles = append(visibles, n)
+	}
+	fov.Lighted = visibles
+	return fov.Lighted
+}
+
+// SSCConeVisionMap is like SSCVisionMap for a viewer at src facing a
+// direction, with the visible positions restricted to the vision cone.
+// Peripheral vision costs are ignored, as shadow casting is binary.
+func (fov *FOV) SSCConeVisionMap(src Point, maxDepth int, diags bool, passable func(p Point) bool, cone VisionCone) []Point {
+	ps := fov.SSCVisionMap(src, maxDepth, diags, passable)
+	visibles := ps[:0]
+	for _, p := range ps {
+		if !cone.Contains(src, p) {
+			fov.ShadowCasting[fov.idx(p)] = false
+			continue
+		}
+		visibles = append(visibles, p)
+	}
+	fov.Visibles = visibles
+	return fov.Visibles
+}
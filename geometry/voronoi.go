@@ -0,0 +1,158 @@
+package geometry
+
+// VoronoiPartition labels the positions of a range with the index of their
+// nearest seed. The partition follows passable positions, so regions don't
+// leak through walls: a position belongs to the region that reaches it
+// first, where regions grow in order of the distance to their seed.
+type VoronoiPartition struct {
+	Rg     Rect
+	Seeds  []Point
+	Labels []int // seed index per position of Rg, row by row, or -1
+}
+
+// VoronoiPartition computes a Voronoi partition of the PathRange's range
+// around the given seeds. The distance function is typically one of
+// DistanceManhattan, DistanceChebyshev or DistanceSquared (Euclidean).
+// Regions grow through all eight neighbors if diags is true, and through
+// cardinal neighbors only otherwise, as suits DistanceManhattan. Impassable
+// and unreachable positions are labeled -1; a nil passable allows all
+// positions.
+func (pr *PathRange) VoronoiPartition(seeds []Point, distance func(p, q Point) int, passable func(Point) bool, diags bool) *VoronoiPartition {
+	rg := pr.Rg
+	size := rg.Size()
+	v := &VoronoiPartition{
+		Rg:     rg,
+		Seeds:  seeds,
+		Labels: make([]int, size.X*size.Y),
+	}
+	for i := range v.Labels {
+		v.Labels[i] = -1
+	}
+	if passable == nil {
+		passable = func(Point) bool { return true }
+	}
+
+	nodes := make([]node, size.X*size.Y)
+	nq := make(priorityQueue, 0, len(seeds))
+	for label, seed := range seeds {
+		if !seed.In(rg) || !passable(seed) {
+			continue
+		}
+		i := v.idx(seed)
+		if v.Labels[i] >= 0 {
+			continue
+		}
+		v.Labels[i] = label
+		n := &nodes[i]
+		*n = node{P: seed, Open: true}
+		pqPush(&nq, n)
+	}
+	nb := &Neighbors{}
+	keep := func(q Point) bool {
+		return q.In(rg) && passable(q)
+	}
+	for nq.Len() > 0 {
+		n := pqPop(&nq)
+		n.Open = false
+		n.Closed = true
+		label := v.Labels[v.idx(n.P)]
+		var neighbors []Point
+		if diags {
+			neighbors = nb.All(n.P, keep)
+		} else {
+			neighbors = nb.Cardinal(n.P, keep)
+		}
+		for _, q := range neighbors {
+			qi := v.idx(q)
+			qNode := &nodes[qi]
+			if qNode.Closed {
+				continue
+			}
+			d := distance(q, seeds[label])
+			if v.Labels[qi] >= 0 && qNode.Rank <= d {
+				continue
+			}
+			if qNode.Open {
+				pqRemove(&nq, qNode.Idx)
+			}
+			v.Labels[qi] = label
+			*qNode = node{P: q, Rank: d, Open: true}
+			pqPush(&nq, qNode)
+		}
+	}
+	return v
+}
+
+func (v *VoronoiPartition) idx(p Point) int {
+	p = p.Sub(v.Rg.Min)
+	return p.Y*v.Rg.Size().X + p.X
+}
+
+// LabelAt returns the index of the seed of the region of a position, or -1
+// if the position is out of range, impassable or unreachable.
+func (v *VoronoiPartition) LabelAt(p Point) int {
+	if !p.In(v.Rg) {
+		return -1
+	}
+	return v.Labels[v.idx(p)]
+}
+
+// Region returns the positions of the region of a seed.
+func (v *VoronoiPartition) Region(label int) []Point {
+	var region []Point
+	v.Rg.Iter(func(p Point) {
+		if v.Labels[v.idx(p)] == label {
+			region = append(region, p)
+		}
+	})
+	return region
+}
+
+// IsBorder reports whether a labeled position has a cardinal neighbor in
+// another region.
+func (v *VoronoiPartition) IsBorder(p Point) bool {
+	label := v.LabelAt(p)
+	if label < 0 {
+		return false
+	}
+	for _, dir := range []Point{RelativeNorth, RelativeEast, RelativeSouth, RelativeWest} {
+		if other := v.LabelAt(p.Add(dir)); other >= 0 && other != label {
+			return true
+		}
+	}
+	return false
+}
+
+// Borders returns all border positions, see IsBorder.
+func (v *VoronoiPartition) Borders() []Point {
+	var borders []Point
+	v.Rg.Iter(func(p Point) {
+		if v.IsBorder(p) {
+			borders = append(borders, p)
+		}
+	})
+	return borders
+}
+
+// Adjacent returns the labels of the regions sharing a border with the
+// region of a seed, in increasing order.
+func (v *VoronoiPartition) Adjacent(label int) []int {
+	found := make([]bool, len(v.Seeds))
+	v.Rg.Iter(func(p Point) {
+		if v.Labels[v.idx(p)] != label {
+			return
+		}
+		for _, dir := range []Point{RelativeNorth, RelativeEast, RelativeSouth, RelativeWest} {
+			if other := v.LabelAt(p.Add(dir)); other >= 0 && other != label {
+				found[other] = true
+			}
+		}
+	})
+	var adjacent []int
+	for other, ok := range found {
+		if ok {
+			adjacent = append(adjacent, other)
+		}
+	}
+	return adjacent
+}
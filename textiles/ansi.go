@@ -0,0 +1,616 @@
+package textiles
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/gdamore/tcell/v2"
+	"github.com/memmaker/go/fxtools"
+	"github.com/memmaker/go/geometry"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ANSIArt is a grid of TextIcons, as read from or written to an ANSI art
+// file (.ans). Icons are stored row by row.
+type ANSIArt struct {
+	Size  geometry.Point
+	Icons []TextIcon
+	Sauce *SauceRecord
+}
+
+// NewANSIArt returns a blank grid of the given size, filled with spaces in
+// light gray on black, which is the default state of an ANSI terminal.
+func NewANSIArt(size geometry.Point) ANSIArt {
+	art := ANSIArt{Size: size, Icons: make([]TextIcon, size.X*size.Y)}
+	for i := range art.Icons {
+		art.Icons[i] = blankANSIIcon()
+	}
+	return art
+}
+
+func (a ANSIArt) At(p geometry.Point) TextIcon {
+	if !a.Contains(p) {
+		return TextIcon{}
+	}
+	return a.Icons[p.Y*a.Size.X+p.X]
+}
+
+func (a ANSIArt) Set(p geometry.Point, icon TextIcon) {
+	if !a.Contains(p) {
+		return
+	}
+	a.Icons[p.Y*a.Size.X+p.X] = icon
+}
+
+func (a ANSIArt) Contains(p geometry.Point) bool {
+	return p.X >= 0 && p.Y >= 0 && p.X < a.Size.X && p.Y < a.Size.Y
+}
+
+// ToColorCodedLines returns one string per row, using the same color tags as
+// RGBAToColorCodes. A tag is only emitted when the colors change.
+func (a ANSIArt) ToColorCodedLines() []string {
+	lines := make([]string, a.Size.Y)
+	for y := 0; y < a.Size.Y; y++ {
+		var line strings.Builder
+		var lastFg, lastBg color.RGBA
+		for x := 0; x < a.Size.X; x++ {
+			icon := a.Icons[y*a.Size.X+x]
+			if x == 0 || icon.Fg != lastFg || icon.Bg != lastBg {
+				line.WriteString(RGBAToColorCodes(icon.Fg, icon.Bg))
+				lastFg, lastBg = icon.Fg, icon.Bg
+			}
+			line.WriteString(icon.Text())
+		}
+		lines[y] = line.String()
+	}
+	return lines
+}
+
+// SauceRecord is the metadata block appended to many ANSI art files.
+// See https://www.acid.org/info/sauce/sauce.htm
+type SauceRecord struct {
+	Title    string
+	Author   string
+	Group    string
+	Date     string // CCYYMMDD
+	FileSize uint32
+	DataType byte
+	FileType byte
+	TInfo1   uint16 // width in characters for ANSi files
+	TInfo2   uint16 // height in lines for ANSi files
+	TInfo3   uint16
+	TInfo4   uint16
+	Comments []string
+	TFlags   byte
+	TInfoS   string // font name
+}
+
+const (
+	sauceSize              = 128
+	sauceCommentSize       = 64
+	sauceDataTypeCharacter = 1
+	sauceFileTypeANSi      = 1
+	sauceFlagICEColors     = 1
+)
+
+// UsesICEColors reports whether the blink attribute selects bright
+// background colors instead of blinking text.
+func (s SauceRecord) UsesICEColors() bool {
+	return s.TFlags&sauceFlagICEColors != 0
+}
+
+// ReadANSIFile parses an ANSI art file. See ReadANSI.
+func ReadANSIFile(filename string) (ANSIArt, error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		return ANSIArt{}, openErr
+	}
+	defer file.Close()
+	return ReadANSI(file)
+}
+
+// ReadANSI parses CP437 encoded ANSI art into a grid of TextIcons. It
+// understands SGR colors (16 colors, xterm 256 colors and 24-bit colors),
+// cursor movement, save/restore and erase sequences. If the data ends with a
+// SAUCE record, it is returned in the Sauce field and its width is used for
+// line wrapping. Otherwise the width is 80 columns. The cursor never goes
+// below the SAUCE height, or ansiMaxHeight rows without SAUCE height, so that
+// cursor movements in corrupt files cannot use up all memory.
+func ReadANSI(reader io.Reader) (ANSIArt, error) {
+	data, readErr := io.ReadAll(reader)
+	if readErr != nil {
+		return ANSIArt{}, readErr
+	}
+	data, sauce := splitSauce(data)
+	width, height := 80, ansiMaxHeight
+	if sauce != nil && sauce.TInfo1 > 0 {
+		width = int(sauce.TInfo1)
+	}
+	if sauce != nil && sauce.TInfo2 > 0 {
+		height = int(sauce.TInfo2)
+	}
+	parser := newANSIParser(width, height, sauce != nil && sauce.UsesICEColors())
+	parser.parse(data)
+	art := parser.toArt()
+	art.Sauce = sauce
+	return art, nil
+}
+
+// SaveANSIFile writes the grid as ANSI art. See WriteANSI.
+func SaveANSIFile(filename string, art ANSIArt) error {
+	file, createErr := os.Create(filename)
+	if createErr != nil {
+		return createErr
+	}
+	defer file.Close()
+	return WriteANSI(file, art)
+}
+
+// WriteANSI writes the grid as a classic CP437 .ans file. Colors are mapped
+// to the nearest of the 16 VGA colors and bright backgrounds are written with
+// the blink attribute, setting the iCE colors flag of the SAUCE record.
+// A SAUCE record is always appended. If the grid has none, a new one is
+// created. Its size fields are updated to match the grid.
+func WriteANSI(writer io.Writer, art ANSIArt) error {
+	var body bytes.Buffer
+	iceColors := false
+	lastSGR := ""
+	for y := 0; y < art.Size.Y; y++ {
+		for x := 0; x < art.Size.X; x++ {
+			icon := art.Icons[y*art.Size.X+x]
+			fgIndex := nearestVGAColor(icon.Fg, 16)
+			bgIndex := nearestVGAColor(icon.Bg, 16)
+			if bgIndex >= 8 {
+				iceColors = true
+			}
+			sgr := vgaSGR(fgIndex, bgIndex)
+			if sgr != lastSGR {
+				body.WriteString(sgr)
+				lastSGR = sgr
+			}
+			char := icon.Char
+			if char == 0 {
+				char = ' '
+			}
+			body.WriteByte(fxtools.UnicodeToCP437Byte(char))
+		}
+		body.WriteString("\x1b[0m\r\n")
+		lastSGR = ""
+	}
+
+	sauce := SauceRecord{}
+	if art.Sauce != nil {
+		sauce = *art.Sauce
+	}
+	sauce.DataType = sauceDataTypeCharacter
+	sauce.FileType = sauceFileTypeANSi
+	sauce.FileSize = uint32(body.Len())
+	sauce.TInfo1 = uint16(art.Size.X)
+	sauce.TInfo2 = uint16(art.Size.Y)
+	if iceColors {
+		sauce.TFlags |= sauceFlagICEColors
+	}
+	body.WriteByte(0x1a)
+	body.Write(sauce.encode())
+
+	_, err := writer.Write(body.Bytes())
+	return err
+}
+
+// WriteColorCodedText writes the grid as lines of text with color tags, as
+// returned by ToColorCodedLines.
+func WriteColorCodedText(writer io.StringWriter, art ANSIArt) error {
+	for _, line := range art.ToColorCodedLines() {
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitSauce(data []byte) ([]byte, *SauceRecord) {
+	body := data
+	var sauce *SauceRecord
+	if len(data) >= sauceSize && string(data[len(data)-sauceSize:len(data)-sauceSize+7]) == "SAUCE00" {
+		record := decodeSauce(data[len(data)-sauceSize:])
+		body = data[:len(data)-sauceSize]
+		commentBlockSize := 5 + sauceCommentSize*len(record.Comments)
+		if len(record.Comments) > 0 && len(body) >= commentBlockSize && string(body[len(body)-commentBlockSize:len(body)-commentBlockSize+5]) == "COMNT" {
+			comments := body[len(body)-commentBlockSize+5:]
+			for i := range record.Comments {
+				record.Comments[i] = sauceString(comments[i*sauceCommentSize : (i+1)*sauceCommentSize])
+			}
+			body = body[:len(body)-commentBlockSize]
+		} else {
+			record.Comments = nil
+		}
+		sauce = &record
+	}
+	if eof := bytes.IndexByte(body, 0x1a); eof >= 0 {
+		body = body[:eof]
+	}
+	return body, sauce
+}
+
+func decodeSauce(data []byte) SauceRecord {
+	le16 := func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }
+	le32 := func(b []byte) uint32 {
+		return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+	}
+	return SauceRecord{
+		Title:    sauceString(data[7:42]),
+		Author:   sauceString(data[42:62]),
+		Group:    sauceString(data[62:82]),
+		Date:     sauceString(data[82:90]),
+		FileSize: le32(data[90:94]),
+		DataType: data[94],
+		FileType: data[95],
+		TInfo1:   le16(data[96:98]),
+		TInfo2:   le16(data[98:100]),
+		TInfo3:   le16(data[100:102]),
+		TInfo4:   le16(data[102:104]),
+		Comments: make([]string, data[104]),
+		TFlags:   data[105],
+		TInfoS:   sauceString(data[106:128]),
+	}
+}
+
+func (s SauceRecord) encode() []byte {
+	var out bytes.Buffer
+	if len(s.Comments) > 0 {
+		out.WriteString("COMNT")
+		for _, comment := range s.Comments {
+			out.Write(sauceBytes(comment, sauceCommentSize))
+		}
+	}
+	putLE16 := func(v uint16) { out.Write([]byte{byte(v), byte(v >> 8)}) }
+	out.WriteString("SAUCE00")
+	out.Write(sauceBytes(s.Title, 35))
+	out.Write(sauceBytes(s.Author, 20))
+	out.Write(sauceBytes(s.Group, 20))
+	out.Write(sauceBytes(s.Date, 8))
+	out.Write([]byte{byte(s.FileSize), byte(s.FileSize >> 8), byte(s.FileSize >> 16), byte(s.FileSize >> 24)})
+	out.WriteByte(s.DataType)
+	out.WriteByte(s.FileType)
+	putLE16(s.TInfo1)
+	putLE16(s.TInfo2)
+	putLE16(s.TInfo3)
+	putLE16(s.TInfo4)
+	out.WriteByte(byte(len(s.Comments)))
+	out.WriteByte(s.TFlags)
+	tInfoS := make([]byte, 22) // zero padded, unlike the other strings
+	copy(tInfoS, fxtools.UnicodeToCP437Bytes(s.TInfoS))
+	out.Write(tInfoS)
+	return out.Bytes()
+}
+
+func sauceString(data []byte) string {
+	return strings.TrimRight(fxtools.CP437ToString(bytes.TrimRight(data, "\x00")), " ")
+}
+
+func sauceBytes(value string, size int) []byte {
+	result := bytes.Repeat([]byte{' '}, size)
+	copy(result, fxtools.UnicodeToCP437Bytes(value))
+	return result
+}
+
+// vgaColors are the 16 colors of the VGA text mode, in ANSI order.
+var vgaColors = [16]color.RGBA{
+	{R: 0, G: 0, B: 0, A: 255},
+	{R: 170, G: 0, B: 0, A: 255},
+	{R: 0, G: 170, B: 0, A: 255},
+	{R: 170, G: 85, B: 0, A: 255},
+	{R: 0, G: 0, B: 170, A: 255},
+	{R: 170, G: 0, B: 170, A: 255},
+	{R: 0, G: 170, B: 170, A: 255},
+	{R: 170, G: 170, B: 170, A: 255},
+	{R: 85, G: 85, B: 85, A: 255},
+	{R: 255, G: 85, B: 85, A: 255},
+	{R: 85, G: 255, B: 85, A: 255},
+	{R: 255, G: 255, B: 85, A: 255},
+	{R: 85, G: 85, B: 255, A: 255},
+	{R: 255, G: 85, B: 255, A: 255},
+	{R: 85, G: 255, B: 255, A: 255},
+	{R: 255, G: 255, B: 255, A: 255},
+}
+
+func nearestVGAColor(rgba color.RGBA, count int) int {
+	best, bestDistance := 0, -1
+	for i := 0; i < count; i++ {
+		dr := int(rgba.R) - int(vgaColors[i].R)
+		dg := int(rgba.G) - int(vgaColors[i].G)
+		db := int(rgba.B) - int(vgaColors[i].B)
+		distance := dr*dr + dg*dg + db*db
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+func vgaSGR(fgIndex, bgIndex int) string {
+	codes := []string{"0"}
+	if fgIndex >= 8 {
+		codes = append(codes, "1")
+	}
+	if bgIndex >= 8 {
+		codes = append(codes, "5")
+	}
+	codes = append(codes, strconv.Itoa(30+fgIndex%8), strconv.Itoa(40+bgIndex%8))
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+func xterm256Color(index int) color.RGBA {
+	switch {
+	case index < 16:
+		return vgaColors[index]
+	case index < 232:
+		index -= 16
+		level := func(v int) uint8 {
+			if v == 0 {
+				return 0
+			}
+			return uint8(55 + v*40)
+		}
+		return color.RGBA{R: level(index / 36), G: level(index / 6 % 6), B: level(index % 6), A: 255}
+	default:
+		gray := uint8(8 + (index-232)*10)
+		return color.RGBA{R: gray, G: gray, B: gray, A: 255}
+	}
+}
+
+func blankANSIIcon() TextIcon {
+	return TextIcon{Char: ' ', Fg: vgaColors[7], Bg: vgaColors[0]}
+}
+
+// ansiMaxHeight is the number of rows of ANSI art without SAUCE height.
+const ansiMaxHeight = 10000
+
+type ansiParser struct {
+	width     int
+	height    int // the cursor stays above this row
+	iceColors bool
+	rows      [][]TextIcon
+	cursor    geometry.Point
+	saved     geometry.Point
+	wrapNext  bool
+	fgIndex   int // -1 if fg holds a color set by 256 or 24-bit codes
+	bgIndex   int
+	fg        color.RGBA
+	bg        color.RGBA
+	bold      bool
+	blink     bool
+	reverse   bool
+}
+
+func newANSIParser(width, height int, iceColors bool) *ansiParser {
+	p := &ansiParser{width: width, height: height, iceColors: iceColors}
+	p.resetAttributes()
+	return p
+}
+
+func (p *ansiParser) resetAttributes() {
+	p.fgIndex, p.bgIndex = 7, 0
+	p.bold, p.blink, p.reverse = false, false, false
+}
+
+func (p *ansiParser) parse(data []byte) {
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case '\r':
+			p.cursor.X = 0
+			p.wrapNext = false
+		case '\n':
+			p.cursor.X = 0
+			p.setY(p.cursor.Y + 1)
+			p.wrapNext = false
+		case '\t':
+			p.cursor.X = min(p.width-1, (p.cursor.X/8+1)*8)
+		case 0x1b:
+			if next, _ := reader.Peek(1); len(next) == 1 && next[0] == '[' {
+				reader.ReadByte()
+				p.parseCSI(reader)
+			}
+		default:
+			p.put(fxtools.CP437ToRune(b))
+		}
+	}
+}
+
+func (p *ansiParser) parseCSI(reader *bufio.Reader) {
+	var params strings.Builder
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		if b >= 0x40 && b <= 0x7e {
+			p.execute(b, params.String())
+			return
+		}
+		params.WriteByte(b)
+	}
+}
+
+func (p *ansiParser) execute(command byte, rawParams string) {
+	if strings.HasPrefix(rawParams, "?") {
+		return // private modes, eg. line wrapping or cursor visibility
+	}
+	var params []int
+	if rawParams != "" {
+		for _, part := range strings.Split(rawParams, ";") {
+			value, _ := strconv.Atoi(part)
+			params = append(params, value)
+		}
+	}
+	param := func(index, defaultValue int) int {
+		if index < len(params) && params[index] > 0 {
+			return params[index]
+		}
+		return defaultValue
+	}
+	p.wrapNext = false
+	switch command {
+	case 'A':
+		p.setY(p.cursor.Y - param(0, 1))
+	case 'B':
+		p.setY(p.cursor.Y + param(0, 1))
+	case 'C':
+		p.cursor.X = min(p.width-1, p.cursor.X+param(0, 1))
+	case 'D':
+		p.cursor.X = max(0, p.cursor.X-param(0, 1))
+	case 'H', 'f':
+		p.cursor.X = min(p.width, param(1, 1)) - 1
+		p.setY(param(0, 1) - 1)
+	case 's':
+		p.saved = p.cursor
+	case 'u':
+		p.cursor = p.saved
+	case 'J':
+		if param(0, 0) == 2 {
+			p.rows = nil
+			p.cursor = geometry.Point{}
+		}
+	case 'K':
+		row := p.row(p.cursor.Y)
+		for x := p.cursor.X; x < len(row); x++ {
+			row[x] = p.currentIcon(' ')
+		}
+	case 'm':
+		p.selectGraphicRendition(params)
+	}
+}
+
+func (p *ansiParser) selectGraphicRendition(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		code := params[i]
+		switch {
+		case code == 0:
+			p.resetAttributes()
+		case code == 1:
+			p.bold = true
+		case code == 5:
+			p.blink = true
+		case code == 7:
+			p.reverse = true
+		case code == 22:
+			p.bold = false
+		case code == 25:
+			p.blink = false
+		case code == 27:
+			p.reverse = false
+		case code >= 30 && code <= 37:
+			p.fgIndex = code - 30
+		case code == 39:
+			p.fgIndex = 7
+		case code >= 40 && code <= 47:
+			p.bgIndex = code - 40
+		case code == 49:
+			p.bgIndex = 0
+		case code >= 90 && code <= 97:
+			p.fgIndex = code - 90 + 8
+		case code >= 100 && code <= 107:
+			p.bgIndex = code - 100 + 8
+		case code == 38 || code == 48:
+			extended, consumed := parseExtendedColor(params[i+1:])
+			i += consumed
+			if consumed == 0 {
+				continue
+			}
+			if code == 38 {
+				p.fgIndex, p.fg = -1, extended
+			} else {
+				p.bgIndex, p.bg = -1, extended
+			}
+		}
+	}
+}
+
+func parseExtendedColor(params []int) (color.RGBA, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		return xterm256Color(params[1] & 0xff), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return color.RGBA{R: uint8(params[1]), G: uint8(params[2]), B: uint8(params[3]), A: 255}, 4
+	}
+	return color.RGBA{}, 0
+}
+
+func (p *ansiParser) currentIcon(char rune) TextIcon {
+	fg, bg := p.fg, p.bg
+	if p.fgIndex >= 0 {
+		index := p.fgIndex
+		if p.bold && index < 8 {
+			index += 8
+		}
+		fg = vgaColors[index]
+	}
+	if p.bgIndex >= 0 {
+		index := p.bgIndex
+		if p.blink && p.iceColors && index < 8 {
+			index += 8
+		}
+		bg = vgaColors[index]
+	}
+	icon := TextIcon{Char: char, Fg: fg, Bg: bg}
+	if p.blink && !p.iceColors {
+		icon.Attributes |= tcell.AttrBlink
+	}
+	if p.reverse {
+		icon = icon.Reversed()
+	}
+	return icon
+}
+
+// setY moves the cursor to a row, staying within the height.
+func (p *ansiParser) setY(y int) {
+	p.cursor.Y = max(0, min(p.height-1, y))
+}
+
+func (p *ansiParser) row(y int) []TextIcon {
+	for len(p.rows) <= y {
+		row := make([]TextIcon, p.width)
+		for i := range row {
+			row[i] = blankANSIIcon()
+		}
+		p.rows = append(p.rows, row)
+	}
+	return p.rows[y]
+}
+
+// put writes a glyph at the cursor. Wrapping is deferred until the next
+// glyph, so that full width lines followed by CR LF don't produce blank
+// lines.
+func (p *ansiParser) put(char rune) {
+	if p.wrapNext {
+		p.cursor.X = 0
+		p.setY(p.cursor.Y + 1)
+		p.wrapNext = false
+	}
+	p.row(p.cursor.Y)[p.cursor.X] = p.currentIcon(char)
+	if p.cursor.X == p.width-1 {
+		p.wrapNext = true
+	} else {
+		p.cursor.X++
+	}
+}
+
+func (p *ansiParser) toArt() ANSIArt {
+	art := ANSIArt{Size: geometry.Point{X: p.width, Y: len(p.rows)}}
+	art.Icons = make([]TextIcon, 0, p.width*len(p.rows))
+	for _, row := range p.rows {
+		art.Icons = append(art.Icons, row...)
+	}
+	return art
+}
@@ -0,0 +1,35 @@
+package textiles
+
+import (
+	"github.com/memmaker/go/geometry"
+	"image/color"
+)
+
+// DiagnosticsIcons are the icons used by PathDiagnosticsOverlay for each
+// kind of position of interest of a path search.
+type DiagnosticsIcons map[geometry.PathMark]TextIcon
+
+// DefaultDiagnosticsIcons returns icons for path diagnostics overlays,
+// matching the characters of PathDiagnostics.ASCII.
+func DefaultDiagnosticsIcons() DiagnosticsIcons {
+	black := color.RGBA{A: 255}
+	return DiagnosticsIcons{
+		geometry.MarkExplored: {Char: '+', Fg: color.RGBA{R: 90, G: 90, B: 160, A: 255}, Bg: black},
+		geometry.MarkPath:     {Char: '*', Fg: color.RGBA{R: 255, G: 220, B: 60, A: 255}, Bg: black},
+		geometry.MarkStart:    {Char: 'S', Fg: color.RGBA{R: 80, G: 255, B: 80, A: 255}, Bg: black},
+		geometry.MarkGoal:     {Char: 'G', Fg: color.RGBA{R: 255, G: 80, B: 80, A: 255}, Bg: black},
+	}
+}
+
+// PathDiagnosticsOverlay returns the icons to draw over a map to show the
+// explored positions, the path, the start and the goal of a path search.
+// Marks without an icon are not drawn.
+func PathDiagnosticsOverlay(d *geometry.PathDiagnostics, icons DiagnosticsIcons) map[geometry.Point]TextIcon {
+	overlay := make(map[geometry.Point]TextIcon)
+	d.Marks(func(p geometry.Point, mark geometry.PathMark) {
+		if icon, ok := icons[mark]; ok {
+			overlay[p] = icon
+		}
+	})
+	return overlay
+}
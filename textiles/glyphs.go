@@ -0,0 +1,149 @@
+package textiles
+
+import (
+	"github.com/memmaker/go/core"
+	"github.com/memmaker/go/geometry"
+	"github.com/rivo/uniseg"
+)
+
+// FirstGrapheme returns the first grapheme cluster of s, eg. a whole emoji
+// sequence instead of only its first rune.
+func FirstGrapheme(s string) string {
+	cluster, _, _, _ := uniseg.FirstGraphemeClusterInString(s, -1)
+	return cluster
+}
+
+// WithGrapheme returns the icon showing the given grapheme cluster.
+func (t TextIcon) WithGrapheme(cluster string) TextIcon {
+	t.Char, t.Grapheme = core.SplitGrapheme(cluster)
+	return t
+}
+
+// Text returns the grapheme cluster shown by the icon.
+func (t TextIcon) Text() string {
+	return core.GraphemeText(t.Char, t.Grapheme)
+}
+
+// Runes returns the main rune and the combining runes, in the form expected
+// by tcell.Screen.SetContent.
+func (t TextIcon) Runes() (rune, []rune) {
+	if t.Grapheme == "" {
+		return t.Char, nil
+	}
+	runes := []rune(t.Grapheme)
+	return runes[0], runes[1:]
+}
+
+// Width returns the number of terminal cells the icon occupies, 1 or 2.
+func (t TextIcon) Width() int {
+	width := uniseg.StringWidth(t.Text())
+	if width < 1 {
+		return 1
+	}
+	if width > 2 {
+		return 2
+	}
+	return width
+}
+
+func (t TextIcon) IsWide() bool {
+	return t.Width() > 1
+}
+
+// WideGlyphPolicy controls how a GlyphLayout handles double-width glyphs.
+type WideGlyphPolicy int
+
+const (
+	// WideGlyphOccupyTwoCells draws wide glyphs across their own and the
+	// following map cell, which is not drawn.
+	WideGlyphOccupyTwoCells WideGlyphPolicy = iota
+	// WideGlyphSubstitute replaces wide glyphs with a narrow substitute.
+	WideGlyphSubstitute
+	// WideGlyphHalfWidth gives every map cell two screen columns, using
+	// geometry.Point.ToHalfWidth coordinates. Narrow glyphs are padded with
+	// a space.
+	WideGlyphHalfWidth
+)
+
+// GlyphLayout maps icons at map positions to screen cells, so that tile sets
+// with double-width glyphs don't shift the following map columns.
+type GlyphLayout struct {
+	Policy            WideGlyphPolicy
+	Substitutes       map[string]rune // per glyph substitutes for WideGlyphSubstitute
+	DefaultSubstitute rune            // used if there is no entry in Substitutes
+}
+
+// PlacedGlyph is an icon at a screen position.
+type PlacedGlyph struct {
+	Screen geometry.Point
+	Icon   TextIcon
+}
+
+func NewGlyphLayout(policy WideGlyphPolicy) GlyphLayout {
+	return GlyphLayout{Policy: policy, Substitutes: make(map[string]rune), DefaultSubstitute: '?'}
+}
+
+// ScreenPosition returns the screen position of a map position.
+func (l GlyphLayout) ScreenPosition(mapPos geometry.Point) geometry.Point {
+	if l.Policy == WideGlyphHalfWidth {
+		return mapPos.ToHalfWidth()
+	}
+	return mapPos
+}
+
+// MapPosition returns the map position shown at a screen position.
+func (l GlyphLayout) MapPosition(screenPos geometry.Point) geometry.Point {
+	if l.Policy == WideGlyphHalfWidth {
+		return geometry.Point{X: screenPos.X / 2, Y: screenPos.Y}
+	}
+	return screenPos
+}
+
+// ScreenRect returns the screen area covered by a map area.
+func (l GlyphLayout) ScreenRect(mapRect geometry.Rect) geometry.Rect {
+	if l.Policy == WideGlyphHalfWidth {
+		return mapRect.ToHalfWidth()
+	}
+	return mapRect
+}
+
+// Substitute returns the narrow replacement of a wide icon.
+func (l GlyphLayout) Substitute(icon TextIcon) TextIcon {
+	if substitute, ok := l.Substitutes[icon.Text()]; ok {
+		return icon.WithRune(substitute)
+	}
+	return icon.WithRune(l.DefaultSubstitute)
+}
+
+// Place returns the screen cells for an icon at a map position.
+func (l GlyphLayout) Place(mapPos geometry.Point, icon TextIcon) []PlacedGlyph {
+	screenPos := l.ScreenPosition(mapPos)
+	switch l.Policy {
+	case WideGlyphSubstitute:
+		if icon.IsWide() {
+			icon = l.Substitute(icon)
+		}
+	case WideGlyphHalfWidth:
+		if !icon.IsWide() {
+			return []PlacedGlyph{
+				{Screen: screenPos, Icon: icon},
+				{Screen: screenPos.Add(geometry.RelativeEast), Icon: icon.WithRune(' ')},
+			}
+		}
+	}
+	return []PlacedGlyph{{Screen: screenPos, Icon: icon}}
+}
+
+// PlaceRow lays out a row of icons starting at a map position. With
+// WideGlyphOccupyTwoCells, the icon following a wide glyph is skipped.
+func (l GlyphLayout) PlaceRow(mapStart geometry.Point, icons []TextIcon) []PlacedGlyph {
+	placed := make([]PlacedGlyph, 0, len(icons))
+	for i := 0; i < len(icons); i++ {
+		mapPos := mapStart.Add(geometry.Point{X: i})
+		placed = append(placed, l.Place(mapPos, icons[i])...)
+		if l.Policy == WideGlyphOccupyTwoCells && icons[i].IsWide() {
+			i++
+		}
+	}
+	return placed
+}
@@ -28,12 +28,23 @@ func (c IconRecord) WithBackground(rgba string) IconRecord {
 }
 func (c IconRecord) WithIconChar(char rune) IconRecord {
 	c.Icon.Char = char
+	c.Icon.Grapheme = ""
+	return c
+}
+
+// WithIconGrapheme sets the icon to a grapheme cluster, eg. an emoji
+// sequence.
+func (c IconRecord) WithIconGrapheme(cluster string) IconRecord {
+	c.Icon = c.Icon.WithGrapheme(cluster)
 	return c
 }
 func (c IconRecord) WithName(name string) IconRecord {
 	c.Name = name
 	return c
 }
+func (c IconRecord) ToTextIcon(palette ColorPalette) TextIcon {
+	return NewTextIconFromNamedColorChar(c.Icon, palette)
+}
 func (c IconRecord) ToRecordWithPosition(mapPos geometry.Point) recfile.Record {
 	record := c.ToRecord()
 	return record.WithKeyValueIgnoreCase("Position", mapPos.Encode())
@@ -81,7 +92,7 @@ func WriteIconRecords(writer io.StringWriter, categories []IconRecord) error {
 func (c IconRecord) ToRecord() recfile.Record {
 	record := recfile.Record{}
 	record = append(record, recfile.Field{Name: "Name", Value: c.Name})
-	record = append(record, recfile.Field{Name: "Icon", Value: string(c.Icon.Char)})
+	record = append(record, recfile.Field{Name: "Icon", Value: c.Icon.Text()})
 	record = append(record, recfile.Field{Name: "Foreground", Value: c.Icon.Fg})
 	if c.Icon.HasBackground() {
 		record = append(record, recfile.Field{Name: "Background", Value: c.Icon.Bg})
@@ -99,7 +110,7 @@ func NewIconRecord(record recfile.Record) IconRecord {
 		case "name":
 			category.Name = field.Value
 		case "icon":
-			category.Icon.Char = []rune(field.Value)[0]
+			category.Icon = category.Icon.WithGrapheme(FirstGrapheme(field.Value))
 		case "foreground":
 			category.Icon.Fg = field.Value
 		case "background":
@@ -11,25 +11,26 @@ type TextIcon struct {
 	Fg         color.RGBA
 	Bg         color.RGBA
 	Attributes tcell.AttrMask
+	Grapheme   string // full grapheme cluster if it has more than one rune, Char is its first rune
 }
 
 func (t TextIcon) Reversed() TextIcon {
-	return TextIcon{t.Char, t.Bg, t.Fg, t.Attributes}
+	return TextIcon{t.Char, t.Bg, t.Fg, t.Attributes, t.Grapheme}
 }
 func (t TextIcon) WithFg(newFg color.RGBA) TextIcon {
-	return TextIcon{t.Char, newFg, t.Bg, t.Attributes}
+	return TextIcon{t.Char, newFg, t.Bg, t.Attributes, t.Grapheme}
 }
 
 func (t TextIcon) WithBg(newBg color.RGBA) TextIcon {
-	return TextIcon{t.Char, t.Fg, newBg, t.Attributes}
+	return TextIcon{t.Char, t.Fg, newBg, t.Attributes, t.Grapheme}
 }
 
 func (t TextIcon) WithColors(fgColor color.RGBA, bgColor color.RGBA) TextIcon {
-	return TextIcon{t.Char, fgColor, bgColor, t.Attributes}
+	return TextIcon{t.Char, fgColor, bgColor, t.Attributes, t.Grapheme}
 }
 
 func (t TextIcon) WithRune(r rune) TextIcon {
-	return TextIcon{r, t.Fg, t.Bg, t.Attributes}
+	return TextIcon{r, t.Fg, t.Bg, t.Attributes, ""}
 }
 
 func (t TextIcon) WithItalic() TextIcon {
@@ -48,8 +49,9 @@ func (t TextIcon) HasBackground() bool {
 
 func NewTextIconFromNamedColorChar(ncc core.NamedColorChar, palette ColorPalette) TextIcon {
 	return TextIcon{
-		Char: ncc.Char,
-		Fg:   palette.Get(ncc.Fg),
-		Bg:   palette.Get(ncc.Bg),
+		Char:     ncc.Char,
+		Fg:       palette.Get(ncc.Fg),
+		Bg:       palette.Get(ncc.Bg),
+		Grapheme: ncc.Grapheme,
 	}
 }
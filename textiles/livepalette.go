@@ -0,0 +1,150 @@
+package textiles
+
+import (
+	"image/color"
+	"sync"
+)
+
+// LivePalette holds the active ColorPalette and a set of named alternatives,
+// so the palette can be switched at runtime, eg. between day and night.
+// Everything drawn with TextTile.IconIn or NewTextIconFromNamedColorChar
+// against Current is recolored on the next frame. It is safe for concurrent
+// use.
+type LivePalette struct {
+	mutex     sync.RWMutex
+	current   string
+	palettes  map[string]ColorPalette
+	listeners []func(name string, palette ColorPalette)
+}
+
+// NewLivePalette returns a LivePalette with the given palette registered
+// under name and activated.
+func NewLivePalette(name string, palette ColorPalette) *LivePalette {
+	return &LivePalette{
+		current:  name,
+		palettes: map[string]ColorPalette{name: palette},
+	}
+}
+
+// Register adds or replaces a named palette. If it is the active one, the
+// change listeners are notified.
+func (l *LivePalette) Register(name string, palette ColorPalette) {
+	l.mutex.Lock()
+	l.palettes[name] = palette
+	isCurrent := name == l.current
+	l.mutex.Unlock()
+	if isCurrent {
+		l.notify(name, palette)
+	}
+}
+
+// Switch activates the palette registered under name. It returns false if
+// there is no such palette.
+func (l *LivePalette) Switch(name string) bool {
+	l.mutex.Lock()
+	palette, ok := l.palettes[name]
+	if ok {
+		l.current = name
+	}
+	l.mutex.Unlock()
+	if ok {
+		l.notify(name, palette)
+	}
+	return ok
+}
+
+// OnChange registers a function that is called after the active palette
+// changed, eg. to update cview.Styles or to redraw the screen.
+func (l *LivePalette) OnChange(listener func(name string, palette ColorPalette)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.listeners = append(l.listeners, listener)
+}
+
+func (l *LivePalette) notify(name string, palette ColorPalette) {
+	l.mutex.RLock()
+	listeners := make([]func(string, ColorPalette), len(l.listeners))
+	copy(listeners, l.listeners)
+	l.mutex.RUnlock()
+	for _, listener := range listeners {
+		listener(name, palette)
+	}
+}
+
+func (l *LivePalette) Current() ColorPalette {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.palettes[l.current]
+}
+
+func (l *LivePalette) CurrentName() string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.current
+}
+
+func (l *LivePalette) Names() []string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	names := make([]string, 0, len(l.palettes))
+	for name := range l.palettes {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Get resolves a color name in the active palette.
+func (l *LivePalette) Get(name string) color.RGBA {
+	return l.Current().Get(name)
+}
+
+// Icon resolves a tile's icon in the active palette.
+func (l *LivePalette) Icon(tile TextTile) TextIcon {
+	return tile.IconIn(l.Current())
+}
+
+// ToSepia is a color mapping for ColorPalette.WithColorsMapped.
+func ToSepia(c color.RGBA) color.RGBA {
+	r, g, b := float64(c.R), float64(c.G), float64(c.B)
+	return color.RGBA{
+		R: clampToByte(0.393*r + 0.769*g + 0.189*b),
+		G: clampToByte(0.349*r + 0.686*g + 0.168*b),
+		B: clampToByte(0.272*r + 0.534*g + 0.131*b),
+		A: c.A,
+	}
+}
+
+// ToHighContrast is a color mapping for ColorPalette.WithColorsMapped. It
+// pushes every channel to either 0 or 255.
+func ToHighContrast(c color.RGBA) color.RGBA {
+	push := func(v uint8) uint8 {
+		if v >= 128 {
+			return 255
+		}
+		return 0
+	}
+	return color.RGBA{R: push(c.R), G: push(c.G), B: push(c.B), A: c.A}
+}
+
+// Darkened returns a color mapping for ColorPalette.WithColorsMapped that
+// scales the brightness by factor, eg. 0.4 for a night palette.
+func Darkened(factor float64) func(color.RGBA) color.RGBA {
+	return func(c color.RGBA) color.RGBA {
+		return color.RGBA{
+			R: clampToByte(float64(c.R) * factor),
+			G: clampToByte(float64(c.G) * factor),
+			B: clampToByte(float64(c.B) * factor),
+			A: c.A,
+		}
+	}
+}
+
+func clampToByte(value float64) uint8 {
+	if value < 0 {
+		return 0
+	}
+	if value > 255 {
+		return 255
+	}
+	return uint8(value)
+}
@@ -42,6 +42,17 @@ func (c ColorPalette) WithColorChanged(index int, rgba color.RGBA) ColorPalette
 	return NewPaletteFromNamedColors(newColors)
 }
 
+// WithColorsMapped returns a copy of the palette with every color passed
+// through mapColor, keeping all names. It's useful for deriving variants of
+// a palette, like night, sepia or high contrast versions.
+func (c ColorPalette) WithColorsMapped(mapColor func(color.RGBA) color.RGBA) ColorPalette {
+	newColors := make([]NamedColor, len(c.colors))
+	for i, namedColor := range c.colors {
+		newColors[i] = NamedColor{Name: namedColor.Name, Color: mapColor(namedColor.Color)}
+	}
+	return NewPaletteFromNamedColors(newColors)
+}
+
 func (c ColorPalette) WithColorRenamed(oldName, newName string) ColorPalette {
 	newPalette := ColorPalette{make(map[string]int), make([]NamedColor, len(c.colors))}
 	for name, colorIndex := range c.names {
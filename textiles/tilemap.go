@@ -0,0 +1,134 @@
+package textiles
+
+import (
+	"github.com/memmaker/go/geometry"
+)
+
+// TileMap combines a grid of tile indices, as stored by SaveTileMap16, with
+// the tile set the indices refer to. It implements geometry.Dijkstra and
+// geometry.Astar, and AsLighter adapts it to geometry.Lighter, so it can be
+// passed directly to PathRange and FOV methods.
+type TileMap struct {
+	Size       geometry.Point
+	Tiles      []int16
+	TileSet    []TextTile
+	Diagonals  bool    // allow diagonal movement in Neighbors
+	LightRange float64 // MaxCost for light propagation
+	nb         geometry.Neighbors
+	minCost    int
+}
+
+func NewTileMap(size geometry.Point, tiles []int16, tileSet []TextTile) *TileMap {
+	m := &TileMap{
+		Size:       size,
+		Tiles:      tiles,
+		TileSet:    tileSet,
+		Diagonals:  true,
+		LightRange: float64(max(size.X, size.Y)),
+	}
+	m.updateMinCost()
+	return m
+}
+
+// ReadTileMap loads a tile map file written by SaveTileMap16.
+func ReadTileMap(filename string, tileSet []TextTile) *TileMap {
+	size, tiles := ReadTileMap16(filename)
+	return NewTileMap(size, tiles, tileSet)
+}
+
+func (m *TileMap) Save(filename string) error {
+	return SaveTileMap16(m.Tiles, m.Size, filename)
+}
+
+// SetTileSet replaces the tile set, eg. after reloading it.
+func (m *TileMap) SetTileSet(tileSet []TextTile) {
+	m.TileSet = tileSet
+	m.updateMinCost()
+}
+
+func (m *TileMap) updateMinCost() {
+	m.minCost = 0
+	for _, tile := range m.TileSet {
+		if tile.IsWalkable && (m.minCost == 0 || tile.MovementCost() < m.minCost) {
+			m.minCost = tile.MovementCost()
+		}
+	}
+	if m.minCost == 0 {
+		m.minCost = 1
+	}
+}
+
+func (m *TileMap) Contains(p geometry.Point) bool {
+	return p.X >= 0 && p.Y >= 0 && p.X < m.Size.X && p.Y < m.Size.Y
+}
+
+func (m *TileMap) TileIndexAt(p geometry.Point) int16 {
+	return m.Tiles[p.Y*m.Size.X+p.X]
+}
+
+func (m *TileMap) TileAt(p geometry.Point) TextTile {
+	return m.TileSet[m.TileIndexAt(p)]
+}
+
+func (m *TileMap) SetTileIndex(p geometry.Point, index int16) {
+	m.Tiles[p.Y*m.Size.X+p.X] = index
+}
+
+func (m *TileMap) IsWalkable(p geometry.Point) bool {
+	return m.Contains(p) && m.TileAt(p).IsWalkable
+}
+
+func (m *TileMap) IsTransparent(p geometry.Point) bool {
+	return m.Contains(p) && m.TileAt(p).IsTransparent
+}
+
+// Neighbors implements geometry.Pather. Only walkable positions are
+// returned.
+func (m *TileMap) Neighbors(p geometry.Point) []geometry.Point {
+	if m.Diagonals {
+		return m.nb.All(p, m.IsWalkable)
+	}
+	return m.nb.Cardinal(p, m.IsWalkable)
+}
+
+// Cost implements geometry.Dijkstra. It is the movement cost of the
+// destination tile.
+func (m *TileMap) Cost(from geometry.Point, to geometry.Point) int {
+	return m.TileAt(to).MovementCost()
+}
+
+// Estimation implements geometry.Astar. The distance is scaled by the
+// cheapest walkable tile of the tile set, so it never overestimates.
+func (m *TileMap) Estimation(from geometry.Point, to geometry.Point) int {
+	if m.Diagonals {
+		return geometry.DistanceChebyshev(from, to) * m.minCost
+	}
+	return geometry.DistanceManhattan(from, to) * m.minCost
+}
+
+// LightCost is the light propagation cost used by AsLighter. Passing through
+// a tile costs 1 plus its light blocking fraction of the whole light range.
+func (m *TileMap) LightCost(src, from, to geometry.Point) float64 {
+	if src == from {
+		return 1
+	}
+	return 1 + m.TileAt(from).LightBlocking()*m.LightRange
+}
+
+// AsLighter returns a geometry.Lighter for the map. It is a separate value
+// because the Cost methods of geometry.Lighter and geometry.Dijkstra clash.
+func (m *TileMap) AsLighter() geometry.Lighter {
+	return tileMapLighter{m}
+}
+
+type tileMapLighter struct {
+	m *TileMap
+}
+
+func (l tileMapLighter) Cost(src, from, to geometry.Point) float64 {
+	return l.m.LightCost(src, from, to)
+}
+
+func (l tileMapLighter) MaxCost(src geometry.Point) float64 {
+	return l.m.LightRange
+}
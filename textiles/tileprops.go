@@ -0,0 +1,75 @@
+package textiles
+
+import (
+	"github.com/memmaker/go/recfile"
+	"strings"
+)
+
+// TileProperties holds the gameplay properties of a tile beyond walkability
+// and transparency. In a tiles .rec file they are read from the fields
+// MovementCost, LightBlocking, Flammability and Tags (comma separated). All
+// other unknown fields are kept in Extra.
+type TileProperties struct {
+	MovementCost  int     // cost of entering the tile, 0 is treated as 1
+	LightBlocking float64 // fraction of light range consumed when passing through, 1 blocks completely
+	Flammability  float64
+	Tags          []string
+	Extra         recfile.Record
+}
+
+func (p TileProperties) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the extra field with the given name, ignoring case.
+func (p TileProperties) Get(name string) (recfile.Field, bool) {
+	return p.Extra.FindFieldIgnoreCase(name)
+}
+
+func (p TileProperties) GetIntOrDefault(name string, defaultValue int) int {
+	if field, ok := p.Get(name); ok {
+		return field.AsInt()
+	}
+	return defaultValue
+}
+
+func (p TileProperties) GetFloatOrDefault(name string, defaultValue float64) float64 {
+	if field, ok := p.Get(name); ok {
+		return field.AsFloat()
+	}
+	return defaultValue
+}
+
+func (p TileProperties) GetStringOrDefault(name string, defaultValue string) string {
+	if field, ok := p.Get(name); ok {
+		return field.Value
+	}
+	return defaultValue
+}
+
+func (t TextTile) WithProperties(properties TileProperties) TextTile {
+	t.Properties = properties
+	return t
+}
+
+// MovementCost returns the cost of entering the tile, at least 1.
+func (t TextTile) MovementCost() int {
+	if t.Properties.MovementCost < 1 {
+		return 1
+	}
+	return t.Properties.MovementCost
+}
+
+// LightBlocking returns the fraction of light blocked by the tile. Tiles
+// that are not transparent always block light completely.
+func (t TextTile) LightBlocking() float64 {
+	if !t.IsTransparent {
+		return 1
+	}
+	return t.Properties.LightBlocking
+}
@@ -2,17 +2,22 @@ package textiles
 
 import (
 	"encoding/binary"
+	"github.com/memmaker/go/core"
 	"github.com/memmaker/go/geometry"
 	"github.com/memmaker/go/recfile"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type TextTile struct {
 	Name          string
 	Icon          TextIcon
+	NamedIcon     core.NamedColorChar // palette references Icon was resolved from
 	IsWalkable    bool
 	IsTransparent bool
+	Properties    TileProperties
 }
 
 func (t TextTile) WithIcon(icon TextIcon) TextTile {
@@ -20,6 +25,33 @@ func (t TextTile) WithIcon(icon TextIcon) TextTile {
 	return t
 }
 
+// IconIn resolves the tile's palette references in the given palette, so
+// that switching palettes at runtime recolors the tile without reloading it.
+// Tiles without palette references return their Icon unchanged.
+func (t TextTile) IconIn(palette ColorPalette) TextIcon {
+	if t.NamedIcon.Fg == "" && t.NamedIcon.Bg == "" {
+		return t.Icon
+	}
+	icon := t.Icon
+	if t.NamedIcon.Fg != "" {
+		icon.Fg = palette.Get(t.NamedIcon.Fg)
+	}
+	if t.NamedIcon.Bg != "" {
+		icon.Bg = palette.Get(t.NamedIcon.Bg)
+	}
+	return icon
+}
+
+// ResolveTiles returns a copy of the tiles with their Icon resolved in the
+// given palette. It's useful for code that reads the Icon field directly.
+func ResolveTiles(tiles []TextTile, palette ColorPalette) []TextTile {
+	resolved := make([]TextTile, len(tiles))
+	for i, tile := range tiles {
+		resolved[i] = tile.WithIcon(tile.IconIn(palette))
+	}
+	return resolved
+}
+
 func ReadTilesFile(reader io.Reader, palette ColorPalette) []TextTile {
 	records := recfile.Read(reader)
 	tiles := make([]TextTile, len(records))
@@ -36,21 +68,67 @@ func recordToTile(record recfile.Record, palette ColorPalette) TextTile {
 		switch field.Name {
 		case "Name":
 			tile.Name = field.Value
-		case "Char":
-			icon.Char = []rune(field.Value)[0]
+		case "Char", "Icon":
+			icon = icon.WithGrapheme(FirstGrapheme(field.Value))
+			tile.NamedIcon.Char, tile.NamedIcon.Grapheme = icon.Char, icon.Grapheme
 		case "Foreground":
 			icon.Fg = palette.Get(field.Value)
+			tile.NamedIcon.Fg = field.Value
 		case "Background":
 			icon.Bg = palette.Get(field.Value)
+			tile.NamedIcon.Bg = field.Value
 		case "IsWalkable":
 			tile.IsWalkable = field.AsBool()
 		case "IsTransparent":
 			tile.IsTransparent = field.AsBool()
+		case "MovementCost":
+			tile.Properties.MovementCost = field.AsInt()
+		case "LightBlocking":
+			tile.Properties.LightBlocking = field.AsFloat()
+		case "Flammability":
+			tile.Properties.Flammability = field.AsFloat()
+		case "Tags":
+			for _, tag := range field.AsList(",") {
+				tile.Properties.Tags = append(tile.Properties.Tags, tag.Value)
+			}
+		default:
+			tile.Properties.Extra = append(tile.Properties.Extra, field)
 		}
 	}
 	return tile.WithIcon(icon)
 }
 
+// ToIconRecord converts the tile to an IconRecord, keeping its palette
+// references and storing all other fields in Meta. Records written with
+// WriteIconRecords can be read back with ReadTilesFile.
+func (t TextTile) ToIconRecord() IconRecord {
+	record := IconRecord{Name: t.Name, Icon: t.NamedIcon}
+	record.Icon.Char, record.Icon.Grapheme = t.Icon.Char, t.Icon.Grapheme
+	meta := recfile.Record{
+		{Name: "IsWalkable", Value: recfile.BoolStr(t.IsWalkable)},
+		{Name: "IsTransparent", Value: recfile.BoolStr(t.IsTransparent)},
+	}
+	if t.Properties.MovementCost != 0 {
+		meta = append(meta, recfile.Field{Name: "MovementCost", Value: recfile.IntStr(t.Properties.MovementCost)})
+	}
+	if t.Properties.LightBlocking != 0 {
+		meta = append(meta, recfile.Field{Name: "LightBlocking", Value: strconv.FormatFloat(t.Properties.LightBlocking, 'f', -1, 64)})
+	}
+	if t.Properties.Flammability != 0 {
+		meta = append(meta, recfile.Field{Name: "Flammability", Value: strconv.FormatFloat(t.Properties.Flammability, 'f', -1, 64)})
+	}
+	if len(t.Properties.Tags) > 0 {
+		meta = append(meta, recfile.Field{Name: "Tags", Value: strings.Join(t.Properties.Tags, ", ")})
+	}
+	record.Meta = append(meta, t.Properties.Extra...)
+	return record
+}
+
+// NewTileFromIconRecord is the inverse of TextTile.ToIconRecord.
+func NewTileFromIconRecord(record IconRecord, palette ColorPalette) TextTile {
+	return recordToTile(record.ToRecord(), palette)
+}
+
 func SaveTileMap16(tiles []int16, dimension geometry.Point, filename string) error {
 	file, openErr := os.Create(filename)
 	if openErr != nil {